@@ -1,23 +1,137 @@
+// Command test-md checks markdown → Notion blocks → markdown conversion.
+//
+// With no arguments it reads markdown from stdin and prints the blocks and
+// the round-tripped markdown. With -suite it runs every *.md fixture in a
+// directory and compares the output against the matching *.golden file:
+//
+//	go run ./cmd/test-md < page.md
+//	go run ./cmd/test-md -suite cmd/test-md/testdata
+//	go run ./cmd/test-md -suite cmd/test-md/testdata -update
 package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/vthunder/efficient-notion-mcp/notion"
 )
 
 func main() {
-	input, _ := io.ReadAll(os.Stdin)
-	blocks := notion.MarkdownToBlocks(string(input))
+	suite := flag.String("suite", "", "run the conformance fixtures in this directory")
+	update := flag.Bool("update", false, "rewrite .golden files with the current output")
+	run := flag.String("run", "", "only run fixtures whose name contains this string")
+	flag.Parse()
+
+	if *suite == "" {
+		input, _ := io.ReadAll(os.Stdin)
+		fmt.Print(convert(string(input)))
+		return
+	}
+
+	failed, err := runSuite(*suite, *run, *update)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// convert renders the blocks for input as JSON followed by the round-trip
+// markdown. This is both the stdin output and the golden file format.
+func convert(input string) string {
+	blocks := notion.MarkdownToBlocks(input)
 
-	// Pretty print the blocks
 	out, _ := json.MarshalIndent(blocks, "", "  ")
-	fmt.Println(string(out))
+	var b strings.Builder
+	b.Write(out)
+	b.WriteString("\n\n--- Round-trip markdown ---\n")
+	b.WriteString(notion.BlocksToMarkdown(blocks))
+	return b.String()
+}
+
+// runSuite converts each fixture and compares it with its golden file.
+// It returns the number of failing fixtures.
+func runSuite(dir, filter string, update bool) (int, error) {
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return 0, err
+	}
+	if len(fixtures) == 0 {
+		return 0, fmt.Errorf("no .md fixtures in %s", dir)
+	}
+	sort.Strings(fixtures)
+
+	failed, ran := 0, 0
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".md")
+		if filter != "" && !strings.Contains(name, filter) {
+			continue
+		}
+		ran++
+
+		input, err := os.ReadFile(fixture)
+		if err != nil {
+			return failed, err
+		}
+		got := convert(string(input))
+
+		goldenPath := strings.TrimSuffix(fixture, ".md") + ".golden"
+		if update {
+			if err := os.WriteFile(goldenPath, []byte(got), 0644); err != nil {
+				return failed, err
+			}
+			fmt.Printf("UPDATE %s\n", name)
+			continue
+		}
+
+		want, err := os.ReadFile(goldenPath)
+		if err != nil {
+			fmt.Printf("FAIL   %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		if got != string(want) {
+			fmt.Printf("FAIL   %s\n%s", name, lineDiff(string(want), got))
+			failed++
+			continue
+		}
+		fmt.Printf("ok     %s\n", name)
+	}
+
+	if !update {
+		fmt.Printf("\n%d/%d fixtures passed\n", ran-failed, ran)
+	}
+	return failed, nil
+}
+
+// lineDiff shows the first differing line between want and got, with a
+// little context.
+func lineDiff(want, got string) string {
+	w := strings.Split(want, "\n")
+	g := strings.Split(got, "\n")
+	i := 0
+	for i < len(w) && i < len(g) && w[i] == g[i] {
+		i++
+	}
 
-	// Convert back to markdown
-	fmt.Println("\n--- Round-trip markdown ---")
-	fmt.Println(notion.BlocksToMarkdown(blocks))
+	var b strings.Builder
+	from := max(i-2, 0)
+	for j := from; j < i; j++ {
+		fmt.Fprintf(&b, "         %s\n", w[j])
+	}
+	for j := i; j < min(i+3, len(w)); j++ {
+		fmt.Fprintf(&b, "  want - %s\n", w[j])
+	}
+	for j := i; j < min(i+3, len(g)); j++ {
+		fmt.Fprintf(&b, "  got  + %s\n", g[j])
+	}
+	return b.String()
 }
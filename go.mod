@@ -2,7 +2,11 @@ module github.com/vthunder/efficient-notion-mcp
 
 go 1.23
 
-require github.com/mark3labs/mcp-go v0.29.0
+require (
+	github.com/mark3labs/mcp-go v0.29.0
+	github.com/yuin/goldmark v1.8.6
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/google/uuid v1.6.0 // indirect
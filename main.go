@@ -4,8 +4,12 @@
 //   - Pull: Download Notion pages as markdown with frontmatter and comments
 //   - Push: Upload markdown back to Notion (erase+replace, not block-by-block)
 //   - Diff: Compare local markdown against live Notion content
+//   - Search: Find pages and databases by title across the workspace
+//   - Local search: Full-text search over pulled markdown without API calls
 //   - Query: Query databases with filters, returns flattened JSON
+//   - Aggregate: Count/sum/avg/min/max over a database, grouped by properties
 //   - Schema: Get database schema (property names and types)
+//   - Schema as code: Export a database schema to YAML and apply it back with drift detection
 //
 // The push operation uses PATCH /pages/{id} with erase_content=true for
 // single-call content clearing, which is dramatically faster than deleting
@@ -17,6 +21,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -35,8 +41,13 @@ func main() {
 	s.AddTool(pullTool(), handlePull)
 	s.AddTool(pushTool(), handlePush)
 	s.AddTool(diffTool(), handleDiff)
+	s.AddTool(searchTool(), handleSearch)
+	s.AddTool(localSearchTool(), handleLocalSearch)
 	s.AddTool(queryTool(), handleQuery)
+	s.AddTool(aggregateTool(), handleAggregate)
 	s.AddTool(schemaTool(), handleSchema)
+	s.AddTool(schemaExportTool(), handleSchemaExport)
+	s.AddTool(schemaApplyTool(), handleSchemaApply)
 
 	// Run server
 	if err := server.ServeStdio(s); err != nil {
@@ -61,6 +72,9 @@ func pullTool() mcp.Tool {
 		mcp.WithBoolean("recursive",
 			mcp.Description("Whether to scan scope directory recursively. Default: true"),
 		),
+		mcp.WithBoolean("lossless",
+			mcp.Description("Keep underlines, text colors and block colors, written as inline HTML and {color=...} attributes. Default: false"),
+		),
 	)
 }
 
@@ -73,6 +87,7 @@ func handlePull(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 	if r, ok := args["recursive"].(bool); ok {
 		recursive = r
 	}
+	lossless, _ := args["lossless"].(bool)
 
 	if pageID == "" {
 		return mcp.NewToolResultError("page_id is required"), nil
@@ -83,7 +98,7 @@ func handlePull(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 		return mcp.NewToolResultError(fmt.Sprintf("failed to create client: %v", err)), nil
 	}
 
-	result, err := client.PullPageWithScope(pageID, outputDir, scope, recursive)
+	result, err := client.PullPageWithScope(pageID, outputDir, scope, recursive, lossless)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to pull page: %v", err)), nil
 	}
@@ -112,6 +127,9 @@ func pushTool() mcp.Tool {
 		mcp.WithBoolean("recursive",
 			mcp.Description("Whether to scan scope directory recursively. Default: true"),
 		),
+		mcp.WithBoolean("edit_synced",
+			mcp.Description("Update the original of a synced block whose content was edited, which changes it everywhere it appears. Without this, edited synced content is an error. Default: false"),
+		),
 	)
 }
 
@@ -123,6 +141,7 @@ func handlePush(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 	if r, ok := args["recursive"].(bool); ok {
 		recursive = r
 	}
+	editSynced, _ := args["edit_synced"].(bool)
 
 	if filePath == "" {
 		return mcp.NewToolResultError("file_path is required"), nil
@@ -133,7 +152,7 @@ func handlePush(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 		return mcp.NewToolResultError(fmt.Sprintf("failed to create client: %v", err)), nil
 	}
 
-	if err := client.PushPageWithScope(filePath, scope, recursive); err != nil {
+	if err := client.PushPageWithScope(filePath, scope, recursive, editSynced); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to push page: %v", err)), nil
 	}
 
@@ -171,6 +190,113 @@ func handleDiff(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 	return mcp.NewToolResultText(diff), nil
 }
 
+func searchTool() mcp.Tool {
+	return mcp.NewTool("notion_search",
+		mcp.WithDescription("Search pages and databases shared with the integration by title. Returns compact results (id, title, parent, URL, last_edited_time) for use with notion_pull and notion_query."),
+		mcp.WithString("query",
+			mcp.Description("Text to search for in titles. Omit to list everything shared with the integration."),
+		),
+		mcp.WithString("object_type",
+			mcp.Description("Only return \"page\" or \"database\" results"),
+		),
+		mcp.WithString("sort",
+			mcp.Description("Sort by last edited time: \"descending\" or \"ascending\". Default: relevance"),
+		),
+		mcp.WithString("start_cursor",
+			mcp.Description("Cursor from a previous result's next_cursor, to fetch the next page"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum results to return (1-100, default 20)"),
+		),
+	)
+}
+
+func handleSearch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := req.Params.Arguments.(map[string]any)
+	query, _ := args["query"].(string)
+	objectType, _ := args["object_type"].(string)
+	sortDirection, _ := args["sort"].(string)
+	cursor, _ := args["start_cursor"].(string)
+
+	limit := 20
+	if l, ok := args["limit"].(float64); ok {
+		limit = int(l)
+	}
+
+	client, err := notion.NewClient()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to create client: %v", err)), nil
+	}
+
+	result, err := client.Search(query, objectType, sortDirection, cursor, limit)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to search: %v", err)), nil
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(output)), nil
+}
+
+func localSearchTool() mcp.Tool {
+	return mcp.NewTool("notion_local_search",
+		mcp.WithDescription("Full-text search over pulled markdown files in a scope directory, without any Notion API calls. The index is kept in memory and refreshed incrementally from file modification times. Returns file path, notion_id, heading path and a snippet for each matching section."),
+		mcp.WithString("scope",
+			mcp.Required(),
+			mcp.Description("Directory containing pulled .md files with notion_id frontmatter"),
+		),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Words and \"quoted phrases\", combined with AND (default), OR, NOT or -word, and parentheses"),
+		),
+		mcp.WithBoolean("recursive",
+			mcp.Description("Whether to scan scope directory recursively. Default: true"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum results to return. Default: 20"),
+		),
+	)
+}
+
+func handleLocalSearch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := req.Params.Arguments.(map[string]any)
+	scope, _ := args["scope"].(string)
+	query, _ := args["query"].(string)
+	recursive := true // default
+	if r, ok := args["recursive"].(bool); ok {
+		recursive = r
+	}
+	limit := 20
+	if l, ok := args["limit"].(float64); ok {
+		limit = int(l)
+	}
+
+	if scope == "" {
+		return mcp.NewToolResultError("scope is required"), nil
+	}
+	if query == "" {
+		return mcp.NewToolResultError("query is required"), nil
+	}
+
+	results, err := notion.LocalSearch(scope, recursive, query, limit)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to search: %v", err)), nil
+	}
+	if len(results) == 0 {
+		return mcp.NewToolResultText("No matches found."), nil
+	}
+
+	output, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(output)), nil
+}
+
 func queryTool() mcp.Tool {
 	return mcp.NewTool("notion_query",
 		mcp.WithDescription("Query a Notion database. Returns flattened JSON with property values extracted (not nested Notion format)."),
@@ -181,12 +307,24 @@ func queryTool() mcp.Tool {
 		mcp.WithObject("filter",
 			mcp.Description("Notion filter object (e.g., {\"property\": \"Status\", \"status\": {\"equals\": \"Active\"}})"),
 		),
+		mcp.WithString("where",
+			mcp.Description("Filter expression, type-checked against the database schema (e.g., Status = \"Active\" AND Priority in (\"P0\", \"P1\") AND Due < today+7d). Supports =, !=, <, <=, >, >=, contains, starts_with, ends_with, in (...), is empty, is not empty, AND, OR, NOT and parentheses. Combined with filter using AND if both are given."),
+		),
 		mcp.WithArray("sorts",
 			mcp.Description("Array of sort objects (e.g., [{\"property\": \"Name\", \"direction\": \"ascending\"}])"),
 		),
 		mcp.WithNumber("limit",
 			mcp.Description("Maximum results to return (1-100, default 100)"),
 		),
+		mcp.WithBoolean("expand_relations",
+			mcp.Description("Resolve relation page IDs to {id, title} objects. Default: false"),
+		),
+		mcp.WithNumber("relation_depth",
+			mcp.Description("With expand_relations: 1 returns {id, title}; 2 also flattens properties of related pages; 3+ expands their relations too. Default: 1"),
+		),
+		mcp.WithArray("relation_properties",
+			mcp.Description("With relation_depth >= 2: names of related-page properties to include (default: all)"),
+		),
 	)
 }
 
@@ -198,11 +336,6 @@ func handleQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRes
 		return mcp.NewToolResultError("database_id is required"), nil
 	}
 
-	var filter map[string]any
-	if f, ok := args["filter"].(map[string]any); ok {
-		filter = f
-	}
-
 	var sorts []map[string]any
 	if s, ok := args["sorts"].([]any); ok {
 		for _, item := range s {
@@ -222,11 +355,27 @@ func handleQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRes
 		return mcp.NewToolResultError(fmt.Sprintf("failed to create client: %v", err)), nil
 	}
 
+	filter, err := buildQueryFilter(client, databaseID, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
 	result, err := client.QueryDatabase(databaseID, filter, sorts, limit)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to query database: %v", err)), nil
 	}
 
+	if expand, _ := args["expand_relations"].(bool); expand {
+		depth := 1
+		if d, ok := args["relation_depth"].(float64); ok {
+			depth = int(d)
+		}
+		relationProps := stringArrayArg(args, "relation_properties")
+		if err := client.ExpandRelations(databaseID, result, depth, relationProps); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to expand relations: %v", err)), nil
+		}
+	}
+
 	output, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
@@ -235,6 +384,124 @@ func handleQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRes
 	return mcp.NewToolResultText(string(output)), nil
 }
 
+func aggregateTool() mcp.Tool {
+	return mcp.NewTool("notion_aggregate",
+		mcp.WithDescription("Aggregate a Notion database without returning every row: pages through the whole query and computes count, sum, avg, min and max, grouped by one or more properties. Returns a compact table."),
+		mcp.WithString("database_id",
+			mcp.Required(),
+			mcp.Description("Notion database ID (with or without dashes)"),
+		),
+		mcp.WithArray("group_by",
+			mcp.Description("Property names to group by (e.g., [\"Status\", \"Assignee\"]). Omit for a single total row."),
+		),
+		mcp.WithArray("metrics",
+			mcp.Description("Metrics to compute: \"count\", \"count:Property\" for rows where the property is set, or \"op:Property\" with op one of sum, avg, min, max (e.g., [\"count\", \"sum:Points\"]). Default: [\"count\"]"),
+		),
+		mcp.WithArray("explode",
+			mcp.Description("Multi-valued group_by properties (multi_select, people, relation) to split into one group per value instead of grouping by the combined list"),
+		),
+		mcp.WithObject("filter",
+			mcp.Description("Notion filter object, as for notion_query"),
+		),
+		mcp.WithString("where",
+			mcp.Description("Filter expression, as for notion_query (e.g., Status != \"Done\")"),
+		),
+		mcp.WithNumber("max_rows",
+			mcp.Description("Maximum database rows to aggregate. If more rows match, the result has truncated set and the aggregates are partial. Default: 10000"),
+		),
+	)
+}
+
+func handleAggregate(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := req.Params.Arguments.(map[string]any)
+	databaseID, _ := args["database_id"].(string)
+
+	if databaseID == "" {
+		return mcp.NewToolResultError("database_id is required"), nil
+	}
+
+	groupBy := stringArrayArg(args, "group_by")
+	explode := stringArrayArg(args, "explode")
+
+	var metrics []notion.AggregateMetric
+	for _, spec := range stringArrayArg(args, "metrics") {
+		m, err := notion.ParseAggregateMetric(spec)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		metrics = append(metrics, m)
+	}
+
+	maxRows := 10000
+	if m, ok := args["max_rows"].(float64); ok {
+		maxRows = int(m)
+	}
+
+	client, err := notion.NewClient()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to create client: %v", err)), nil
+	}
+
+	filter, err := buildQueryFilter(client, databaseID, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	result, err := client.Aggregate(databaseID, filter, groupBy, metrics, explode, maxRows)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to aggregate database: %v", err)), nil
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(output)), nil
+}
+
+// stringArrayArg returns the string elements of an array argument.
+func stringArrayArg(args map[string]any, name string) []string {
+	var values []string
+	if arr, ok := args[name].([]any); ok {
+		for _, item := range arr {
+			if v, ok := item.(string); ok {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// buildQueryFilter combines the "filter" and "where" tool arguments into a
+// single Notion filter. The where expression is compiled against the live
+// database schema.
+func buildQueryFilter(client *notion.Client, databaseID string, args map[string]any) (map[string]any, error) {
+	var filter map[string]any
+	if f, ok := args["filter"].(map[string]any); ok {
+		filter = f
+	}
+
+	where, _ := args["where"].(string)
+	if strings.TrimSpace(where) == "" {
+		return filter, nil
+	}
+
+	schema, err := client.GetSchema(databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema: %v", err)
+	}
+	compiled, err := notion.CompileWhere(where, schema, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("invalid where expression: %v", err)
+	}
+
+	if filter == nil {
+		return compiled, nil
+	}
+	return map[string]any{"and": []map[string]any{filter, compiled}}, nil
+}
+
 func schemaTool() mcp.Tool {
 	return mcp.NewTool("notion_schema",
 		mcp.WithDescription("Get the schema of a Notion database (property names and types)."),
@@ -270,3 +537,94 @@ func handleSchema(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 
 	return mcp.NewToolResultText(string(output)), nil
 }
+
+func schemaExportTool() mcp.Tool {
+	return mcp.NewTool("notion_schema_export",
+		mcp.WithDescription("Export the schema of a Notion database as YAML (properties, types, select options, number formats, formulas, relations). Keep the file in version control and use notion_schema_apply to detect or fix drift."),
+		mcp.WithString("database_id",
+			mcp.Required(),
+			mcp.Description("Notion database ID (with or without dashes)"),
+		),
+		mcp.WithString("output_path",
+			mcp.Description("File to write the YAML schema to. If omitted, the YAML is returned directly."),
+		),
+	)
+}
+
+func handleSchemaExport(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := req.Params.Arguments.(map[string]any)
+	databaseID, _ := args["database_id"].(string)
+	outputPath, _ := args["output_path"].(string)
+
+	if databaseID == "" {
+		return mcp.NewToolResultError("database_id is required"), nil
+	}
+
+	client, err := notion.NewClient()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to create client: %v", err)), nil
+	}
+
+	data, err := client.ExportSchema(databaseID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to export schema: %v", err)), nil
+	}
+
+	if outputPath == "" {
+		return mcp.NewToolResultText(string(data)), nil
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to write schema file: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Exported schema to %s", outputPath)), nil
+}
+
+func schemaApplyTool() mcp.Tool {
+	return mcp.NewTool("notion_schema_apply",
+		mcp.WithDescription("Compare a YAML schema file (from notion_schema_export) against the live Notion database and report drift. Set apply=true to send the changes to Notion."),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the YAML schema file (must have database_id)"),
+		),
+		mcp.WithBoolean("apply",
+			mcp.Description("Apply the changes to Notion instead of only reporting them. Default: false"),
+		),
+		mcp.WithBoolean("prune",
+			mcp.Description("Also remove properties and select options that are not in the file. Default: false"),
+		),
+	)
+}
+
+func handleSchemaApply(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := req.Params.Arguments.(map[string]any)
+	filePath, _ := args["file_path"].(string)
+	apply, _ := args["apply"].(bool)
+	prune, _ := args["prune"].(bool)
+
+	if filePath == "" {
+		return mcp.NewToolResultError("file_path is required"), nil
+	}
+
+	client, err := notion.NewClient()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to create client: %v", err)), nil
+	}
+
+	result, err := client.ApplySchema(filePath, apply, prune)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to apply schema: %v", err)), nil
+	}
+
+	if len(result.Changes) == 0 {
+		return mcp.NewToolResultText("No drift detected."), nil
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to marshal result: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(output)), nil
+}
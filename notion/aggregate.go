@@ -0,0 +1,306 @@
+package notion
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// AggregateMetric is one aggregate column: count of rows, or of rows where
+// a property is set, or sum/avg/min/max over a number-valued property.
+type AggregateMetric struct {
+	Op       string // count, sum, avg, min, max
+	Property string // Empty to count all rows
+}
+
+// ParseAggregateMetric parses "count" or "op:Property" (e.g. "sum:Points",
+// "count:Due").
+func ParseAggregateMetric(spec string) (AggregateMetric, error) {
+	spec = strings.TrimSpace(spec)
+	op, prop, _ := strings.Cut(spec, ":")
+	op = strings.ToLower(strings.TrimSpace(op))
+	prop = strings.TrimSpace(prop)
+
+	switch op {
+	case "count":
+		return AggregateMetric{Op: op, Property: prop}, nil
+	case "sum", "avg", "min", "max":
+		if prop == "" {
+			return AggregateMetric{}, fmt.Errorf("metric %q needs a property (e.g. %s:Points)", spec, op)
+		}
+		return AggregateMetric{Op: op, Property: prop}, nil
+	}
+	return AggregateMetric{}, fmt.Errorf("unknown metric %q (expected count, sum, avg, min or max)", spec)
+}
+
+// String returns the column name for the metric.
+func (m AggregateMetric) String() string {
+	if m.Property == "" {
+		return m.Op
+	}
+	return m.Op + ":" + m.Property
+}
+
+// AggregateResult is a compact table of grouped aggregates.
+type AggregateResult struct {
+	Columns   []string `json:"columns"`
+	Rows      [][]any  `json:"rows"`
+	TotalRows int      `json:"total_rows"` // Number of database rows aggregated
+	Truncated bool     `json:"truncated"`  // More rows matched than max_rows; the aggregates are partial
+}
+
+// Aggregate pages through a database query and computes metrics grouped by
+// the given flattened properties. Properties listed in explode that hold
+// multiple values (multi_select, people, relation, ...) contribute one group
+// per value instead of one group for the combined list.
+func (c *Client) Aggregate(databaseID string, filter map[string]any, groupBy []string, metrics []AggregateMetric, explode []string, maxRows int) (*AggregateResult, error) {
+	if len(metrics) == 0 {
+		metrics = []AggregateMetric{{Op: "count"}}
+	}
+
+	rows, truncated, err := c.QueryAllPages(databaseID, filter, nil, maxRows)
+	if err != nil {
+		return nil, err
+	}
+
+	result := aggregateRows(rows, groupBy, metrics, explode)
+	result.Truncated = truncated
+	return result, nil
+}
+
+// aggregateGroup accumulates metric values for one group.
+type aggregateGroup struct {
+	keys  []string
+	count int
+	sums  []float64
+	ns    []int
+	mins  []float64
+	maxs  []float64
+}
+
+func aggregateRows(rows []map[string]any, groupBy []string, metrics []AggregateMetric, explode []string) *AggregateResult {
+	exploded := make(map[string]bool)
+	for _, name := range explode {
+		exploded[name] = true
+	}
+
+	groups := make(map[string]*aggregateGroup)
+	for _, row := range rows {
+		for _, keys := range groupKeyCombinations(row, groupBy, exploded) {
+			id := strings.Join(keys, "\x00")
+			g, ok := groups[id]
+			if !ok {
+				g = &aggregateGroup{
+					keys: keys,
+					sums: make([]float64, len(metrics)),
+					ns:   make([]int, len(metrics)),
+					mins: make([]float64, len(metrics)),
+					maxs: make([]float64, len(metrics)),
+				}
+				groups[id] = g
+			}
+			g.count++
+			for i, m := range metrics {
+				if m.Op == "count" {
+					if m.Property != "" && hasValue(row[m.Property]) {
+						g.ns[i]++
+					}
+					continue
+				}
+				v, ok := numericValue(row[m.Property])
+				if !ok {
+					continue
+				}
+				if g.ns[i] == 0 || v < g.mins[i] {
+					g.mins[i] = v
+				}
+				if g.ns[i] == 0 || v > g.maxs[i] {
+					g.maxs[i] = v
+				}
+				g.sums[i] += v
+				g.ns[i]++
+			}
+		}
+	}
+
+	result := &AggregateResult{TotalRows: len(rows)}
+	result.Columns = append(result.Columns, groupBy...)
+	for _, m := range metrics {
+		result.Columns = append(result.Columns, m.String())
+	}
+
+	ordered := make([]*aggregateGroup, 0, len(groups))
+	for _, g := range groups {
+		ordered = append(ordered, g)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		for k := range ordered[i].keys {
+			if ordered[i].keys[k] != ordered[j].keys[k] {
+				return ordered[i].keys[k] < ordered[j].keys[k]
+			}
+		}
+		return false
+	})
+
+	for _, g := range ordered {
+		row := make([]any, 0, len(result.Columns))
+		for _, k := range g.keys {
+			row = append(row, k)
+		}
+		for i, m := range metrics {
+			row = append(row, g.metricValue(i, m))
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result
+}
+
+func (g *aggregateGroup) metricValue(i int, m AggregateMetric) any {
+	if m.Op == "count" {
+		if m.Property != "" {
+			return g.ns[i]
+		}
+		return g.count
+	}
+	if g.ns[i] == 0 {
+		return nil
+	}
+	switch m.Op {
+	case "sum":
+		return g.sums[i]
+	case "avg":
+		return math.Round(g.sums[i]/float64(g.ns[i])*1000) / 1000
+	case "min":
+		return g.mins[i]
+	case "max":
+		return g.maxs[i]
+	}
+	return nil
+}
+
+// groupKeyCombinations returns the group keys a row belongs to.
+// A row belongs to exactly one group unless an exploded property has
+// several values, in which case it belongs to the cross product.
+func groupKeyCombinations(row map[string]any, groupBy []string, exploded map[string]bool) [][]string {
+	combos := [][]string{{}}
+	for _, name := range groupBy {
+		var values []string
+		if exploded[name] {
+			values = groupValues(row[name])
+		} else {
+			values = []string{groupValue(row[name])}
+		}
+
+		var next [][]string
+		for _, combo := range combos {
+			for _, v := range values {
+				keys := append(append([]string{}, combo...), v)
+				next = append(next, keys)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+const emptyGroup = "(empty)"
+
+// groupValue renders a flattened value as a single group key.
+func groupValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return emptyGroup
+	case string:
+		if val == "" {
+			return emptyGroup
+		}
+		return val
+	case []string:
+		if len(val) == 0 {
+			return emptyGroup
+		}
+		return strings.Join(val, ", ")
+	case []any, []map[string]any:
+		values := groupValues(val)
+		return strings.Join(values, ", ")
+	case map[string]string:
+		return val["start"] + " → " + val["end"]
+	case map[string]any:
+		if isTypeOnly(val) {
+			return emptyGroup
+		}
+	case float64:
+		return fmt.Sprintf("%g", val)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// groupValues splits a multi-valued flattened value into group keys.
+func groupValues(v any) []string {
+	var values []string
+	switch val := v.(type) {
+	case []string:
+		values = append(values, val...)
+	case []any:
+		for _, item := range val {
+			values = append(values, groupValue(item))
+		}
+	case []map[string]any:
+		// Expanded relations: group by title.
+		for _, item := range val {
+			title, _ := item["title"].(string)
+			values = append(values, groupValue(title))
+		}
+	default:
+		return []string{groupValue(v)}
+	}
+	if len(values) == 0 {
+		return []string{emptyGroup}
+	}
+	return values
+}
+
+// hasValue reports whether a flattened value is set.
+func hasValue(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case string:
+		return val != ""
+	case []string:
+		return len(val) > 0
+	case []any:
+		return len(val) > 0
+	case []map[string]any:
+		return len(val) > 0
+	case map[string]string:
+		return val["start"] != ""
+	case map[string]any:
+		return !isTypeOnly(val)
+	}
+	return true
+}
+
+// isTypeOnly reports whether a flattened value is the placeholder for a
+// property type that carries no value, such as a button.
+func isTypeOnly(v map[string]any) bool {
+	_, ok := v["type"].(string)
+	return ok && len(v) == 1
+}
+
+// numericValue extracts a number from a flattened value.
+func numericValue(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,292 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxParallelAppends is how many parents get children appended at once.
+// Appends under one parent stay sequential so blocks keep their order.
+const maxParallelAppends = 3
+
+// appendJob is a list of blocks to append under one parent block or page,
+// at the end or after the block with ID after.
+type appendJob struct {
+	parentID string
+	after    string
+	blocks   []Block
+}
+
+// appendBlocksBatched appends blocks under parentID, however deeply they
+// are nested.
+//
+// The API accepts limited nesting in one request, so each request carries
+// blocks with their direct children only. Once a level is appended, the new
+// block IDs are used to append the next level down. Jobs for different
+// parents run in parallel; each level finishes before the next starts.
+func (c *Client) appendBlocksBatched(parentID string, blocks []Block) error {
+	return c.appendBlocksAfter(parentID, "", blocks)
+}
+
+// appendBlocksAfter is appendBlocksBatched, inserting the blocks after the
+// child with ID afterID instead of at the end. An empty afterID appends at
+// the end.
+func (c *Client) appendBlocksAfter(parentID, afterID string, blocks []Block) error {
+	jobs := []appendJob{{parentID: parentID, after: afterID, blocks: blocks}}
+	for level := 0; len(jobs) > 0; level++ {
+		debugLog("appendBlocksBatched: level %d, %d parent(s)", level, len(jobs))
+		next, err := c.runAppendJobs(jobs)
+		if err != nil {
+			return err
+		}
+		jobs = next
+	}
+	return nil
+}
+
+// runAppendJobs runs jobs in parallel and returns the jobs for the next
+// level down.
+func (c *Client) runAppendJobs(jobs []appendJob) ([]appendJob, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		next     []appendJob
+		firstErr error
+	)
+	sem := make(chan struct{}, maxParallelAppends)
+
+	for _, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(job appendJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			more, err := c.appendLevel(job)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			next = append(next, more...)
+		}(job)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return next, nil
+}
+
+// appendLevel appends one job's blocks with their direct children and
+// returns jobs for whatever is nested deeper.
+func (c *Client) appendLevel(job appendJob) ([]appendJob, error) {
+	shallow := make([]Block, len(job.blocks))
+	for i, b := range job.blocks {
+		shallow[i] = shallowCopy(b)
+	}
+
+	batches, err := planAppendBatches(shallow)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for i, batch := range batches {
+		body := map[string]any{
+			"children": batch,
+		}
+		if job.after != "" {
+			body["after"] = job.after
+		}
+
+		debugLog("appendBlocksBatched: sending batch %d/%d (%d blocks) to %s", i+1, len(batches), len(batch), job.parentID)
+		url := fmt.Sprintf("%s/blocks/%s/children", c.apiBase, job.parentID)
+		resp, err := c.doRequest("PATCH", url, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to append batch %d: %w", i, err)
+		}
+
+		var result struct {
+			Results []struct {
+				ID string `json:"id"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(resp, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse append response: %w", err)
+		}
+		for _, r := range result.Results {
+			ids = append(ids, r.ID)
+		}
+		if job.after != "" && len(ids) > 0 {
+			// The next batch goes after this one
+			job.after = ids[len(ids)-1]
+		}
+
+		if i < len(batches)-1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	if len(ids) != len(job.blocks) {
+		return nil, fmt.Errorf("appended %d blocks under %s but got %d IDs back", len(job.blocks), job.parentID, len(ids))
+	}
+
+	var next []appendJob
+	for i, b := range job.blocks {
+		more, err := c.deferredJobs(ids[i], b, shallow[i])
+		if err != nil {
+			return nil, err
+		}
+		next = append(next, more...)
+	}
+	return next, nil
+}
+
+// deferredJobs returns jobs for the descendants of b that were left out of
+// sent, the copy of b that was created with the given ID.
+func (c *Client) deferredJobs(id string, b, sent Block) ([]appendJob, error) {
+	var jobs []appendJob
+	n := len(sent.Children)
+	if n < len(b.Children) {
+		// Children past the per-request limit go under the same parent
+		jobs = append(jobs, appendJob{parentID: id, blocks: b.Children[n:]})
+	}
+	if !hasDeferredChildren(b.Children[:n], sent.Children) {
+		return jobs, nil
+	}
+
+	childIDs, err := c.fetchChildIDs(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list children of %s: %w", id, err)
+	}
+	if len(childIDs) < n {
+		return nil, fmt.Errorf("block %s has %d children, expected %d", id, len(childIDs), n)
+	}
+	for j := range sent.Children {
+		more, err := c.deferredJobs(childIDs[j], b.Children[j], sent.Children[j])
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, more...)
+	}
+	return jobs, nil
+}
+
+// shallowCopy returns b with at most one level of children. Children's own
+// children are dropped, except where a block can't be created without them:
+// table rows, and the content of a column list's columns. A column list
+// takes up all the nesting one request allows, so a block with a column
+// list among its children is sent without children.
+func shallowCopy(b Block) Block {
+	if keepsChildren(b) {
+		return b
+	}
+	children := b.Children[:min(len(b.Children), maxBlocksPerAppend)]
+	if b.Type != "column_list" && containsColumnList(children) {
+		b.Children = nil
+		return b
+	}
+	b.Children = make([]Block, len(children))
+	for i, child := range children {
+		if b.Type == "column_list" {
+			child = withoutGrandchildren(child)
+		} else if !keepsChildren(child) {
+			child.Children = nil
+		}
+		b.Children[i] = child
+	}
+	return b
+}
+
+// withoutGrandchildren returns b with at most one request's worth of
+// children, and none of their children.
+func withoutGrandchildren(b Block) Block {
+	children := b.Children[:min(len(b.Children), maxBlocksPerAppend)]
+	b.Children = make([]Block, len(children))
+	for i, child := range children {
+		child.Children = nil
+		b.Children[i] = child
+	}
+	return b
+}
+
+// keepsChildren reports whether a block is created together with all of
+// its children.
+func keepsChildren(b Block) bool {
+	return b.Type == "table" && len(b.Children) <= maxBlocksPerAppend
+}
+
+func containsColumnList(blocks []Block) bool {
+	for _, b := range blocks {
+		if b.Type == "column_list" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDeferredChildren reports whether any descendants of blocks were left
+// out of sent, their shallow copies.
+func hasDeferredChildren(blocks, sent []Block) bool {
+	for i, b := range blocks {
+		n := len(sent[i].Children)
+		if n < len(b.Children) || hasDeferredChildren(b.Children[:n], sent[i].Children) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAppendable verifies that every level of blocks fits the API's
+// request limits, without sending anything.
+func checkAppendable(blocks []Block) error {
+	if err := checkTextRuns(blocks); err != nil {
+		return err
+	}
+	shallow := make([]Block, len(blocks))
+	for i, b := range blocks {
+		shallow[i] = shallowCopy(b)
+	}
+	if _, err := planAppendBatches(shallow); err != nil {
+		return err
+	}
+	for i, b := range blocks {
+		if err := checkDeferred(b, shallow[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDeferred checks the descendants of b that were left out of sent for
+// later requests.
+func checkDeferred(b, sent Block) error {
+	n := len(sent.Children)
+	if n < len(b.Children) {
+		if err := checkAppendable(b.Children[n:]); err != nil {
+			return err
+		}
+	}
+	for j := range sent.Children {
+		if err := checkDeferred(b.Children[j], sent.Children[j]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchChildIDs returns the IDs of a block's children, in order.
+func (c *Client) fetchChildIDs(blockID string) ([]string, error) {
+	children, err := c.fetchBlockChildren(blockID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(children))
+	for i, child := range children {
+		ids[i] = child.ID
+	}
+	return ids, nil
+}
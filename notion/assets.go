@@ -0,0 +1,149 @@
+package notion
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// assetsDir is the folder next to a pulled markdown file that holds its
+// downloaded files.
+const assetsDir = "assets"
+
+// downloadAssets saves the Notion-hosted files of media blocks into
+// dir/assets and points the blocks at the local copies, since Notion's
+// file URLs expire after an hour. A file that can't be downloaded keeps
+// its URL.
+func (c *Client) downloadAssets(blocks []Block, dir string) {
+	for i := range blocks {
+		b := &blocks[i]
+		if mediaBlockTypes[b.Type] && b.File != nil && b.File.Type == "file" {
+			assetPath, err := c.downloadAsset(b.File, dir)
+			if err != nil {
+				debugLog("downloadAssets: %s block %s: %v", b.Type, b.ID, err)
+			} else {
+				b.AssetPath = assetPath
+			}
+		}
+		c.downloadAssets(b.Children, dir)
+	}
+}
+
+// downloadAsset downloads one file and returns its path relative to dir.
+func (c *Client) downloadAsset(f *File, dir string) (string, error) {
+	resp, err := c.httpClient.Get(f.URL())
+	if err != nil {
+		return "", fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to download: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, assetsDir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create assets dir: %w", err)
+	}
+	name := assetFileName(fileNameFromURL(f.URL()), data)
+	if err := os.WriteFile(filepath.Join(dir, assetsDir, name), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write asset: %w", err)
+	}
+	return assetsDir + "/" + name, nil
+}
+
+// assetFileName names a downloaded file after its original name and a hash
+// of its content. The same file keeps its name across pulls, and different
+// files with the same name (pasted images are all "image.png") don't
+// overwrite each other.
+func assetFileName(original string, data []byte) string {
+	stem, ext := assetStem(original)
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%s-%x%s", stem, sum[:4], ext)
+}
+
+// assetStem returns the parts of an asset's file name that come from the
+// original name: the stem before the hash, and the extension after it.
+func assetStem(original string) (stem, ext string) {
+	original = strings.ReplaceAll(sanitizeFilename(original), " ", "-")
+	ext = filepath.Ext(original)
+	stem = strings.TrimSuffix(original, ext)
+	if stem == "" || stem == "." {
+		stem = "file"
+	}
+	return stem, ext
+}
+
+// isAssetOf reports whether a local asset path could be a downloaded copy
+// of a file originally named original.
+func isAssetOf(assetPath, original string) bool {
+	stem, ext := assetStem(original)
+	name := path.Base(assetPath)
+	hash, ok := strings.CutPrefix(name, stem+"-")
+	if !ok {
+		return false
+	}
+	hash, ok = strings.CutSuffix(hash, ext)
+	return ok && len(hash) == 8 && strings.Trim(hash, "0123456789abcdef") == ""
+}
+
+// useLocalAssets points the Notion-hosted files of media blocks at the
+// copies a pull saved, so that unchanged media render the same as in the
+// pulled file instead of with their expiring URLs. local are the asset
+// paths in the pulled file, in order; each block takes the next one that
+// has its file's name.
+func useLocalAssets(blocks []Block, local []string) {
+	next := 0
+	var use func(blocks []Block)
+	use = func(blocks []Block) {
+		for i := range blocks {
+			b := &blocks[i]
+			if mediaBlockTypes[b.Type] && b.File != nil && b.File.Type == "file" {
+				original := fileNameFromURL(b.File.URL())
+				for j := next; j < len(local); j++ {
+					if isAssetOf(local[j], original) {
+						b.AssetPath = local[j]
+						next = j + 1
+						break
+					}
+				}
+			}
+			use(b.Children)
+		}
+	}
+	use(blocks)
+}
+
+// localAssetPaths returns the asset paths of the media blocks in blocks,
+// in order.
+func localAssetPaths(blocks []Block) []string {
+	var paths []string
+	for _, b := range blocks {
+		if b.AssetPath != "" {
+			paths = append(paths, b.AssetPath)
+		}
+		paths = append(paths, localAssetPaths(b.Children)...)
+	}
+	return paths
+}
+
+// findLocalAsset returns the path of the first media block that refers to
+// a local file, or "" if there is none.
+func findLocalAsset(blocks []Block) string {
+	for _, b := range blocks {
+		if b.AssetPath != "" {
+			return b.AssetPath
+		}
+		if p := findLocalAsset(b.Children); p != "" {
+			return p
+		}
+	}
+	return ""
+}
@@ -0,0 +1,476 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Block is a Notion block.
+//
+// Content fields are shared between block types and only those that apply to
+// Type are used; MarshalJSON writes them under the type key, the way the API
+// expects ({"type": "to_do", "to_do": {"rich_text": [...], "checked": true}}).
+// Blocks of types the converter does not understand keep their type-specific
+// JSON verbatim so they round-trip untouched.
+type Block struct {
+	ID          string
+	Type        string
+	HasChildren bool
+
+	RichText     []RichText // Text of paragraphs, headings, list items, to_dos, quotes, callouts, toggles and code
+	Color        string     // Block color; empty means "default"
+	Checked      bool       // to_do
+	Language     string     // code
+	Caption      []RichText // code, image, video, audio, file, pdf, bookmark, embed
+	File         *File      // image, video, audio, file, pdf
+	AssetPath    string     // image, video, audio, file, pdf: local copy of the file, relative to the markdown
+	URL          string     // bookmark, embed, link_preview
+	Icon         *Icon      // callout
+	IsToggleable bool       // heading_1, heading_2, heading_3
+	Title        string     // child_page, child_database, link_to_page: title of the target
+	LinkTo       *LinkRef   // link_to_page
+	Expression   string     // equation: LaTeX
+	SyncedFrom   string     // synced_block: ID of the original, or "" for an original
+
+	TableWidth      int          // table
+	HasColumnHeader bool         // table
+	HasRowHeader    bool         // table
+	Cells           [][]RichText // table_row
+
+	Children []Block
+	Comments []Comment // Comments attached to the block, rendered after it
+
+	raw json.RawMessage // Type-specific content of unsupported block types
+}
+
+// RichText is one run of Notion rich text.
+type RichText struct {
+	Type        string       `json:"type"`
+	Text        *Text        `json:"text,omitempty"`
+	Mention     *Mention     `json:"mention,omitempty"`
+	Equation    *Equation    `json:"equation,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	PlainText   string       `json:"plain_text,omitempty"`
+	Href        string       `json:"href,omitempty"`
+}
+
+// Text is the content of a "text" rich text run.
+type Text struct {
+	Content string `json:"content"`
+	Link    *Link  `json:"link,omitempty"`
+}
+
+// Link is a hyperlink on a text run.
+type Link struct {
+	URL string `json:"url"`
+}
+
+// Mention is the content of a "mention" rich text run.
+type Mention struct {
+	Type     string     `json:"type"`
+	Page     *ObjectRef `json:"page,omitempty"`
+	Database *ObjectRef `json:"database,omitempty"`
+	User     *ObjectRef `json:"user,omitempty"`
+	Date     *DateRange `json:"date,omitempty"`
+}
+
+// ObjectRef references a Notion page, database or user by ID.
+type ObjectRef struct {
+	ID string `json:"id"`
+}
+
+// DateRange is a Notion date value.
+type DateRange struct {
+	Start string `json:"start"`
+	End   string `json:"end,omitempty"`
+}
+
+// Equation is the content of an "equation" rich text run.
+type Equation struct {
+	Expression string `json:"expression"`
+}
+
+// Annotations are the styles applied to a rich text run.
+type Annotations struct {
+	Bold          bool   `json:"bold,omitempty"`
+	Italic        bool   `json:"italic,omitempty"`
+	Strikethrough bool   `json:"strikethrough,omitempty"`
+	Underline     bool   `json:"underline,omitempty"`
+	Code          bool   `json:"code,omitempty"`
+	Color         string `json:"color,omitempty"`
+}
+
+// LinkRef is the page or database a link_to_page block points to.
+type LinkRef struct {
+	Type       string `json:"type"` // "page_id" or "database_id"
+	PageID     string `json:"page_id,omitempty"`
+	DatabaseID string `json:"database_id,omitempty"`
+}
+
+// ID returns the ID of the target page or database.
+func (t *LinkRef) ID() string {
+	if t.Type == "database_id" {
+		return t.DatabaseID
+	}
+	return t.PageID
+}
+
+// Icon is a page or callout icon.
+type Icon struct {
+	Type     string   `json:"type"`
+	Emoji    string   `json:"emoji,omitempty"`
+	External *FileRef `json:"external,omitempty"`
+	File     *FileRef `json:"file,omitempty"`
+}
+
+// File is the file of an image, video, audio, file or pdf block.
+type File struct {
+	Type       string     // "external", "file" (hosted by Notion) or "file_upload"
+	External   *FileRef   // external
+	File       *FileRef   // file
+	FileUpload *ObjectRef // file_upload: a file sent with the file upload API, when creating a block
+	Name       string     // file blocks only
+}
+
+// URL returns the file's URL. Notion-hosted file URLs expire after an hour.
+func (f *File) URL() string {
+	switch {
+	case f == nil:
+		return ""
+	case f.External != nil:
+		return f.External.URL
+	case f.File != nil:
+		return f.File.URL
+	}
+	return ""
+}
+
+// FileRef is an externally hosted or Notion-hosted file URL.
+type FileRef struct {
+	URL        string `json:"url"`
+	ExpiryTime string `json:"expiry_time,omitempty"`
+}
+
+// textBlockTypes are the block types whose content is a rich_text array.
+var textBlockTypes = map[string]bool{
+	"paragraph":          true,
+	"heading_1":          true,
+	"heading_2":          true,
+	"heading_3":          true,
+	"bulleted_list_item": true,
+	"numbered_list_item": true,
+	"to_do":              true,
+	"quote":              true,
+	"callout":            true,
+	"toggle":             true,
+	"code":               true,
+}
+
+// knownBlockTypes are the block types with typed content. Everything else
+// keeps its raw JSON.
+var knownBlockTypes = map[string]bool{
+	"divider":      true,
+	"child_page":   true,
+	"table":        true,
+	"table_row":    true,
+	"equation":     true,
+	"image":        true,
+	"video":        true,
+	"audio":        true,
+	"file":         true,
+	"pdf":          true,
+	"bookmark":     true,
+	"embed":        true,
+	"link_preview": true,
+	"column_list":  true,
+	"column":       true,
+	"synced_block": true,
+
+	// Written as placeholders
+	"table_of_contents": true,
+	"breadcrumb":        true,
+	"link_to_page":      true,
+	"child_database":    true,
+}
+
+// mediaBlockTypes are the block types that hold a file.
+var mediaBlockTypes = map[string]bool{
+	"image": true,
+	"video": true,
+	"audio": true,
+	"file":  true,
+	"pdf":   true,
+}
+
+func isKnownBlockType(blockType string) bool {
+	return textBlockTypes[blockType] || knownBlockTypes[blockType]
+}
+
+// blockContent is the type-specific part of a block, as read from the API.
+type blockContent struct {
+	RichText        []RichText   `json:"rich_text"`
+	Color           string       `json:"color"`
+	Checked         bool         `json:"checked"`
+	Language        string       `json:"language"`
+	Caption         []RichText   `json:"caption"`
+	Icon            *Icon        `json:"icon"`
+	IsToggleable    bool         `json:"is_toggleable"`
+	Title           string       `json:"title"`
+	TableWidth      int          `json:"table_width"`
+	HasColumnHeader bool         `json:"has_column_header"`
+	HasRowHeader    bool         `json:"has_row_header"`
+	Cells           [][]RichText `json:"cells"`
+	Children        []Block      `json:"children"`
+	FileType        string       `json:"type"`
+	External        *FileRef     `json:"external"`
+	File            *FileRef     `json:"file"`
+	Name            string       `json:"name"`
+	Expression      string       `json:"expression"`
+	URL             string       `json:"url"`
+	PageID          string       `json:"page_id"`
+	DatabaseID      string       `json:"database_id"`
+	SyncedFrom      *struct {
+		BlockID string `json:"block_id"`
+	} `json:"synced_from"`
+}
+
+// UnmarshalJSON reads a block in the API format.
+func (b *Block) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var envelope struct {
+		ID          string `json:"id"`
+		Type        string `json:"type"`
+		HasChildren bool   `json:"has_children"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+
+	*b = Block{
+		ID:          envelope.ID,
+		Type:        envelope.Type,
+		HasChildren: envelope.HasChildren,
+	}
+
+	raw, ok := fields[b.Type]
+	if !ok {
+		return nil
+	}
+	if !isKnownBlockType(b.Type) {
+		b.raw = append(json.RawMessage(nil), raw...)
+		return nil
+	}
+
+	var content blockContent
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return fmt.Errorf("failed to parse %s block: %w", b.Type, err)
+	}
+	b.RichText = content.RichText
+	if content.Color != "default" {
+		b.Color = content.Color
+	}
+	b.Checked = content.Checked
+	b.Language = content.Language
+	b.Caption = content.Caption
+	b.Icon = content.Icon
+	b.IsToggleable = content.IsToggleable
+	b.Title = content.Title
+	b.TableWidth = content.TableWidth
+	b.HasColumnHeader = content.HasColumnHeader
+	b.HasRowHeader = content.HasRowHeader
+	b.Cells = content.Cells
+	b.Children = content.Children
+	b.Expression = content.Expression
+	b.URL = content.URL
+	if b.Type == "link_to_page" {
+		b.LinkTo = &LinkRef{Type: content.FileType, PageID: content.PageID, DatabaseID: content.DatabaseID}
+	}
+	if content.SyncedFrom != nil {
+		b.SyncedFrom = content.SyncedFrom.BlockID
+	}
+	if mediaBlockTypes[b.Type] {
+		b.File = &File{Type: content.FileType, External: content.External, File: content.File, Name: content.Name}
+	}
+	return nil
+}
+
+// MarshalJSON writes a block in the API format, with children nested in
+// the type-specific content as the append endpoint expects.
+func (b Block) MarshalJSON() ([]byte, error) {
+	out := map[string]any{
+		"object": "block",
+		"type":   b.Type,
+	}
+	if b.ID != "" {
+		out["id"] = b.ID
+	}
+	if b.HasChildren {
+		out["has_children"] = true
+	}
+
+	if !isKnownBlockType(b.Type) {
+		if b.raw != nil {
+			out[b.Type] = b.raw
+		} else {
+			out[b.Type] = map[string]any{}
+		}
+		return json.Marshal(out)
+	}
+
+	content := map[string]any{}
+	if textBlockTypes[b.Type] {
+		content["rich_text"] = nonNilRichText(b.RichText)
+		if b.Color != "" {
+			content["color"] = b.Color
+		}
+	}
+
+	switch b.Type {
+	case "to_do":
+		content["checked"] = b.Checked
+	case "code":
+		content["language"] = b.Language
+		if len(b.Caption) > 0 {
+			content["caption"] = b.Caption
+		}
+	case "image", "video", "audio", "file", "pdf":
+		if b.File != nil {
+			content["type"] = b.File.Type
+			switch b.File.Type {
+			case "external":
+				content["external"] = b.File.External
+			case "file":
+				content["file"] = b.File.File
+			case "file_upload":
+				content["file_upload"] = b.File.FileUpload
+			}
+			if b.Type == "file" && b.File.Name != "" {
+				content["name"] = b.File.Name
+			}
+		}
+		if len(b.Caption) > 0 {
+			content["caption"] = b.Caption
+		}
+	case "callout":
+		if b.Icon != nil {
+			content["icon"] = b.Icon
+		}
+	case "heading_1", "heading_2", "heading_3":
+		if b.IsToggleable {
+			content["is_toggleable"] = true
+		}
+	case "child_page", "child_database":
+		content["title"] = b.Title
+	case "table_of_contents":
+		if b.Color != "" {
+			content["color"] = b.Color
+		}
+	case "link_to_page":
+		if b.LinkTo != nil {
+			content["type"] = b.LinkTo.Type
+			content[b.LinkTo.Type] = b.LinkTo.ID()
+		}
+	case "equation":
+		content["expression"] = b.Expression
+	case "bookmark", "embed", "link_preview":
+		content["url"] = b.URL
+		if len(b.Caption) > 0 && b.Type != "link_preview" {
+			content["caption"] = b.Caption
+		}
+	case "synced_block":
+		if b.SyncedFrom != "" {
+			content["synced_from"] = map[string]any{"type": "block_id", "block_id": b.SyncedFrom}
+		} else {
+			content["synced_from"] = nil
+		}
+	case "table":
+		content["table_width"] = b.TableWidth
+		content["has_column_header"] = b.HasColumnHeader
+		content["has_row_header"] = b.HasRowHeader
+	case "table_row":
+		cells := make([][]RichText, len(b.Cells))
+		for i, cell := range b.Cells {
+			cells[i] = nonNilRichText(cell)
+		}
+		content["cells"] = cells
+	}
+
+	if len(b.Children) > 0 {
+		content["children"] = b.Children
+	}
+	out[b.Type] = content
+	return json.Marshal(out)
+}
+
+// nonNilRichText returns rt, or an empty slice so it marshals as [] instead of null.
+func nonNilRichText(rt []RichText) []RichText {
+	if rt == nil {
+		return []RichText{}
+	}
+	return rt
+}
+
+// newTextBlock creates a block of a rich-text type.
+func newTextBlock(blockType string, richText []RichText) Block {
+	return Block{Type: blockType, RichText: richText}
+}
+
+// plainRichText returns a single unformatted text run.
+func plainRichText(content string) []RichText {
+	return []RichText{{Type: "text", Text: &Text{Content: content}}}
+}
+
+// richTextPlain returns the unformatted text of rich text runs.
+func richTextPlain(richText []RichText) string {
+	var b strings.Builder
+	for _, rt := range richText {
+		if rt.Type == "text" && rt.Text != nil {
+			b.WriteString(rt.Text.Content)
+		} else {
+			b.WriteString(rt.PlainText)
+		}
+	}
+	return b.String()
+}
+
+// cutRichTextLines splits rich text before line n, counting from 0, and
+// drops the line break between the two parts. If the text has no line n,
+// tail is empty.
+func cutRichTextLines(richText []RichText, n int) (head, tail []RichText) {
+	breaks := 0
+	for i, rt := range richText {
+		if rt.Type != "text" || rt.Text == nil {
+			continue
+		}
+		content := rt.Text.Content
+		for j := 0; j < len(content); j++ {
+			if content[j] != '\n' {
+				continue
+			}
+			if breaks++; breaks < n {
+				continue
+			}
+			head = append(richText[:i:i], withContent(rt, content[:j])...)
+			tail = append(withContent(rt, content[j+1:]), richText[i+1:]...)
+			return head, tail
+		}
+	}
+	return richText, nil
+}
+
+// withContent returns a text run like rt with different content, or
+// nothing if content is empty.
+func withContent(rt RichText, content string) []RichText {
+	if content == "" {
+		return nil
+	}
+	text := *rt.Text
+	text.Content = content
+	rt.Text = &text
+	return []RichText{rt}
+}
@@ -0,0 +1,183 @@
+package notion
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Callouts are written as GitHub-style alerts. The first line of the quote
+// names the kind, and the callout text follows:
+//
+//	> [!WARNING]
+//	> Text of the callout
+//
+// Each kind stands for an icon and a background color. A callout with a
+// different icon or color adds them after the kind:
+//
+//	> [!NOTE] 🚀 {color=green_background}
+//
+// Icons that aren't emoji are written as {icon=URL}, and a callout without
+// an icon as {icon=none}.
+
+// calloutKind is a GitHub alert type and the callout it stands for.
+type calloutKind struct {
+	name  string
+	emoji string
+	color string
+}
+
+var calloutKinds = []calloutKind{
+	{"NOTE", "ℹ️", "blue_background"},
+	{"TIP", "💡", "gray_background"},
+	{"IMPORTANT", "❗", "purple_background"},
+	{"WARNING", "⚠️", "yellow_background"},
+	{"CAUTION", "🛑", "red_background"},
+}
+
+// calloutMarker returns the alert line for a callout, without the "> ".
+func calloutMarker(b Block) string {
+	kind := calloutKindFor(b)
+	marker := "[!" + kind.name + "]"
+
+	var attrs []string
+	switch {
+	case b.Icon == nil:
+		attrs = append(attrs, "icon=none")
+	case b.Icon.Type == "emoji":
+		if b.Icon.Emoji != kind.emoji {
+			marker += " " + b.Icon.Emoji
+		}
+	case b.Icon.Type == "external" && b.Icon.External != nil:
+		attrs = append(attrs, "icon="+b.Icon.External.URL)
+	}
+	// Notion-hosted icon files expire, so they fall back to the kind's emoji
+
+	color := b.Color
+	if color == "" {
+		color = "default"
+	}
+	if color != kind.color {
+		attrs = append(attrs, "color="+color)
+	}
+	if len(attrs) > 0 {
+		marker += " {" + strings.Join(attrs, " ") + "}"
+	}
+	return marker
+}
+
+// calloutKindFor picks the kind matching a callout's icon, then its color,
+// and NOTE if neither matches.
+func calloutKindFor(b Block) calloutKind {
+	if b.Icon != nil && b.Icon.Type == "emoji" {
+		for _, kind := range calloutKinds {
+			if kind.emoji == b.Icon.Emoji {
+				return kind
+			}
+		}
+	}
+	for _, kind := range calloutKinds {
+		if kind.color == b.Color {
+			return kind
+		}
+	}
+	return calloutKinds[0]
+}
+
+// parseCalloutMarker reads an alert line such as "[!TIP]" or
+// "[!NOTE] 🚀 {color=green_background}" and returns a callout block with
+// its icon and color. ok is false if line isn't an alert marker, or names
+// a color Notion doesn't have.
+func parseCalloutMarker(line string) (block Block, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[!") {
+		return Block{}, false
+	}
+	end := strings.IndexByte(line, ']')
+	if end < 0 {
+		return Block{}, false
+	}
+	name := strings.ToUpper(line[2:end])
+	var kind *calloutKind
+	for i := range calloutKinds {
+		if calloutKinds[i].name == name {
+			kind = &calloutKinds[i]
+		}
+	}
+	if kind == nil {
+		return Block{}, false
+	}
+
+	block = newTextBlock("callout", nil)
+	block.Icon = &Icon{Type: "emoji", Emoji: kind.emoji}
+	block.Color = kind.color
+
+	rest := strings.TrimSpace(line[end+1:])
+	if rest != "" && rest[0] != '{' {
+		emoji, after, _ := strings.Cut(rest, " ")
+		if !isEmojiToken(emoji) {
+			return Block{}, false
+		}
+		block.Icon = &Icon{Type: "emoji", Emoji: emoji}
+		rest = strings.TrimSpace(after)
+	}
+	if rest != "" {
+		attrs, ok := parseAttributes(rest)
+		if !ok {
+			return Block{}, false
+		}
+		for key, value := range attrs {
+			switch key {
+			case "color":
+				// Notion rejects other colors, so a typo stays text
+				if value != "default" && !isNotionColor(value) {
+					return Block{}, false
+				}
+				block.Color = value
+			case "icon":
+				if value == "none" {
+					block.Icon = nil
+				} else {
+					block.Icon = &Icon{Type: "external", External: &FileRef{URL: value}}
+				}
+			default:
+				return Block{}, false
+			}
+		}
+	}
+	if block.Color == "default" {
+		block.Color = ""
+	}
+	return block, true
+}
+
+// isEmojiToken reports whether s could be an emoji icon: a short token
+// with at least one non-ASCII character. Keycap emoji such as "1️⃣" start
+// with an ASCII digit, "#" or "*".
+func isEmojiToken(s string) bool {
+	if s == "" || utf8.RuneCountInString(s) > 8 {
+		return false
+	}
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAttributes reads an attribute list such as
+// "{color=red icon=none}". ok is false if s isn't one.
+func parseAttributes(s string) (map[string]string, bool) {
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, false
+	}
+	attrs := map[string]string{}
+	for _, field := range strings.Fields(s[1 : len(s)-1]) {
+		key, value, found := strings.Cut(field, "=")
+		if !found || key == "" || value == "" {
+			return nil, false
+		}
+		attrs[key] = value
+	}
+	return attrs, true
+}
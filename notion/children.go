@@ -0,0 +1,76 @@
+package notion
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Children of paragraphs and other blocks without a markdown container of
+// their own are written between HTML comments after the block:
+//
+//	A paragraph with children.
+//
+//	<!-- children -->
+//
+//	A nested paragraph.
+//
+//	<!-- /children -->
+//
+// An indented section would read the same in a viewer, but CommonMark
+// reads it as an indented code block.
+
+const (
+	childrenOpen  = "<!-- children -->"
+	childrenClose = "<!-- /children -->"
+)
+
+// writeChildrenSection writes the children of a paragraph-like block
+// between children markers.
+func writeChildrenSection(result *strings.Builder, children []Block, trailingChildPages map[string]bool) {
+	md := strings.TrimRight(BlocksToMarkdownWithChildPages(children, trailingChildPages), "\n")
+	if md == "" {
+		return
+	}
+	result.WriteString(childrenOpen + "\n\n" + md + "\n\n" + childrenClose + "\n\n")
+}
+
+// holdsChildrenSection reports whether children of b are written as a
+// children section after it. List items and quotes hold their children
+// inside their own markdown container instead.
+func holdsChildrenSection(b Block) bool {
+	return b.Type == "paragraph"
+}
+
+// childrenMarkerAt returns the children marker that nodes[i] consists of,
+// or "" if it isn't one.
+func childrenMarkerAt(nodes []ast.Node, i int, source []byte) string {
+	html, ok := nodes[i].(*ast.HTMLBlock)
+	if !ok {
+		return ""
+	}
+	switch raw := strings.TrimSpace(htmlBlockText(html, source)); raw {
+	case childrenOpen, childrenClose:
+		return raw
+	}
+	return ""
+}
+
+// childrenEnd returns the index of the marker that closes a children
+// section whose content starts at nodes[from], or len(nodes) if it is
+// never closed. Nested sections are skipped over.
+func childrenEnd(nodes []ast.Node, from int, source []byte) int {
+	depth := 1
+	for i := from; i < len(nodes); i++ {
+		switch childrenMarkerAt(nodes, i, source) {
+		case childrenOpen:
+			depth++
+		case childrenClose:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(nodes)
+}
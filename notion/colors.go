@@ -0,0 +1,228 @@
+package notion
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Underlines and colors have no markdown syntax, so they are only written
+// when a page is pulled in lossless mode. Underlined and colored text is
+// wrapped in inline HTML:
+//
+//	<u>underlined</u>
+//	<span style="color:red">red text</span>
+//	<span style="background-color:yellow">highlighted</span>
+//
+// A colored block ends its text with an attribute list:
+//
+//	## Heading {color=blue}
+//	Highlighted paragraph {color=yellow_background}
+//
+// Push always reads these back, whichever way the page was pulled.
+
+// notionColors are the colors Notion has for text and blocks. Each also
+// has a background variant, such as "red_background".
+var notionColors = map[string]bool{
+	"gray":   true,
+	"brown":  true,
+	"orange": true,
+	"yellow": true,
+	"green":  true,
+	"blue":   true,
+	"purple": true,
+	"pink":   true,
+	"red":    true,
+}
+
+func isNotionColor(color string) bool {
+	return notionColors[strings.TrimSuffix(color, "_background")]
+}
+
+// colorTag returns the <span> that opens text in a Notion color.
+func colorTag(color string) string {
+	if base, ok := strings.CutSuffix(color, "_background"); ok {
+		return `<span style="background-color:` + base + `">`
+	}
+	return `<span style="color:` + color + `">`
+}
+
+var colorTagPattern = regexp.MustCompile(`^<span\s+style\s*=\s*"\s*(color|background-color)\s*:\s*([a-z]+)\s*;?\s*"\s*>$`)
+
+// parseColorTag returns the Notion color of a <span> written by colorTag.
+// ok is false if tag isn't one.
+func parseColorTag(tag string) (color string, ok bool) {
+	m := colorTagPattern.FindStringSubmatch(tag)
+	if m == nil || !notionColors[m[2]] {
+		return "", false
+	}
+	if m[1] == "background-color" {
+		return m[2] + "_background", true
+	}
+	return m[2], true
+}
+
+// colorBlockTypes are the block types whose color is written as an
+// attribute list after their text. Callouts write theirs in the alert line.
+var colorBlockTypes = map[string]bool{
+	"paragraph":          true,
+	"heading_1":          true,
+	"heading_2":          true,
+	"heading_3":          true,
+	"bulleted_list_item": true,
+	"numbered_list_item": true,
+	"to_do":              true,
+	"quote":              true,
+	"toggle":             true,
+}
+
+// blockTextWithColor adds a block's color to its markdown text. Text that
+// already ends with something that reads as a color is escaped.
+func blockTextWithColor(text, color string) string {
+	trimmed := strings.TrimRight(text, " \t")
+	if i := colorAttributeAt(trimmed); i >= 0 {
+		text = text[:i] + `\` + text[i:]
+	}
+	if color == "" || color == "default" {
+		return text
+	}
+	if text == "" {
+		return "{color=" + color + "}"
+	}
+	return text + " {color=" + color + "}"
+}
+
+// colorAttributeAt returns the index of a {color=...} list that s ends
+// with, or -1 if it doesn't end with one. The list must be all of s or
+// follow a space.
+func colorAttributeAt(s string) int {
+	i := strings.LastIndexByte(s, '{')
+	if i < 0 || (i > 0 && s[i-1] != ' ') {
+		return -1
+	}
+	if _, ok := parseColorAttribute(s[i:]); !ok {
+		return -1
+	}
+	return i
+}
+
+// parseColorAttribute reads an attribute list that holds only a color,
+// such as "{color=red_background}".
+func parseColorAttribute(s string) (string, bool) {
+	attrs, ok := parseAttributes(s)
+	if !ok || len(attrs) != 1 || !isNotionColor(attrs["color"]) {
+		return "", false
+	}
+	return attrs["color"], true
+}
+
+// cutBlockColor removes the {color=...} list that the text of a block
+// node ends with, and returns the color. It returns "" if there is none,
+// or if its "{" is escaped.
+func cutBlockColor(n ast.Node, source []byte) string {
+	last, ok := n.LastChild().(*ast.Text)
+	if !ok || last.SoftLineBreak() || last.HardLineBreak() {
+		return ""
+	}
+	// Delimiter characters such as "_" may split the list into several
+	// text nodes; take the whole run of adjacent ones
+	first := last
+	for prev, ok := first.PreviousSibling().(*ast.Text); ok && prev.Segment.Stop == first.Segment.Start; prev, ok = first.PreviousSibling().(*ast.Text) {
+		first = prev
+	}
+	start := first.Segment.Start
+	raw := strings.TrimRight(string(source[start:last.Segment.Stop]), " \t")
+	i := colorAttributeAt(raw)
+	if i < 0 || (i == 0 && first.PreviousSibling() != nil) {
+		return ""
+	}
+	color, _ := parseColorAttribute(raw[i:])
+
+	// Cut the text at the end of what comes before the list
+	stop := start + len(strings.TrimRight(raw[:i], " \t"))
+	for t := ast.Node(last); ; {
+		prev := t.PreviousSibling()
+		text := t.(*ast.Text)
+		if text.Segment.Start < stop {
+			text.Segment = text.Segment.WithStop(stop)
+			break
+		}
+		n.RemoveChild(n, t)
+		if t == first {
+			break
+		}
+		t = prev
+	}
+	return color
+}
+
+// withoutColors returns a copy of blocks without underlines and colors,
+// for pulls that aren't lossless. Callouts keep their color, which is part
+// of their alert syntax.
+func withoutColors(blocks []Block) []Block {
+	if blocks == nil {
+		return nil
+	}
+	out := make([]Block, len(blocks))
+	for i, b := range blocks {
+		if b.Type != "callout" {
+			b.Color = ""
+		}
+		b.RichText = richTextWithoutColors(b.RichText)
+		b.Caption = richTextWithoutColors(b.Caption)
+		if b.Cells != nil {
+			cells := make([][]RichText, len(b.Cells))
+			for j, cell := range b.Cells {
+				cells[j] = richTextWithoutColors(cell)
+			}
+			b.Cells = cells
+		}
+		b.Children = withoutColors(b.Children)
+		out[i] = b
+	}
+	return out
+}
+
+func richTextWithoutColors(richText []RichText) []RichText {
+	if richText == nil {
+		return nil
+	}
+	out := make([]RichText, len(richText))
+	for i, rt := range richText {
+		if rt.Annotations != nil {
+			a := *rt.Annotations
+			a.Underline, a.Color = false, ""
+			rt.Annotations = &a
+		}
+		out[i] = rt
+	}
+	return out
+}
+
+// hasColors reports whether any block or text in blocks is underlined or
+// colored, apart from callouts.
+func hasColors(blocks []Block) bool {
+	colored := func(richText []RichText) bool {
+		for _, rt := range richText {
+			if a := rt.Annotations; a != nil && (a.Underline || (a.Color != "" && a.Color != "default")) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, b := range blocks {
+		if b.Type != "callout" && b.Color != "" && b.Color != "default" {
+			return true
+		}
+		if colored(b.RichText) || colored(b.Caption) || hasColors(b.Children) {
+			return true
+		}
+		for _, cell := range b.Cells {
+			if colored(cell) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package notion
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Column layouts are written between HTML comments, which markdown viewers
+// hide, so the columns read as one after the other:
+//
+//	<!-- columns -->
+//
+//	<!-- column -->
+//
+//	Left column
+//
+//	<!-- column -->
+//
+//	Right column
+//
+//	<!-- /columns -->
+
+const (
+	columnsOpen  = "<!-- columns -->"
+	columnMarker = "<!-- column -->"
+	columnsClose = "<!-- /columns -->"
+)
+
+// columnsMarkdown writes a column list and its columns.
+func columnsMarkdown(b Block, trailingChildPages map[string]bool) string {
+	var md strings.Builder
+	md.WriteString(columnsOpen + "\n\n")
+	for _, column := range b.Children {
+		md.WriteString(columnMarker + "\n\n")
+		if content := strings.TrimRight(BlocksToMarkdownWithChildPages(column.Children, trailingChildPages), "\n"); content != "" {
+			md.WriteString(content + "\n\n")
+		}
+	}
+	md.WriteString(columnsClose + "\n\n")
+	return md.String()
+}
+
+// columnsMarkerAt returns the column marker that nodes[i] consists of, or
+// "" if it isn't one.
+func columnsMarkerAt(nodes []ast.Node, i int, source []byte) string {
+	html, ok := nodes[i].(*ast.HTMLBlock)
+	if !ok {
+		return ""
+	}
+	switch raw := strings.TrimSpace(htmlBlockText(html, source)); raw {
+	case columnsOpen, columnMarker, columnsClose:
+		return raw
+	}
+	return ""
+}
+
+// columnsEnd returns the index of the marker that closes a column list
+// whose content starts at nodes[from], or len(nodes) if it is never
+// closed. Nested column lists are skipped over.
+func columnsEnd(nodes []ast.Node, from int, source []byte) int {
+	depth := 1
+	for i := from; i < len(nodes); i++ {
+		switch columnsMarkerAt(nodes, i, source) {
+		case columnsOpen:
+			depth++
+		case columnsClose:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(nodes)
+}
+
+// columnsToBlocks converts the content of a column list, split into columns
+// by column markers. Notion needs at least two columns, so a single column
+// is returned as its content. Content before the first marker is a column
+// of its own.
+func columnsToBlocks(nodes []ast.Node, source []byte) []Block {
+	var parts [][]ast.Node
+	start, depth := 0, 0
+	for i := range nodes {
+		switch columnsMarkerAt(nodes, i, source) {
+		case columnsOpen:
+			depth++
+		case columnsClose:
+			depth--
+		case columnMarker:
+			if depth == 0 {
+				if i > start || len(parts) > 0 {
+					parts = append(parts, nodes[start:i])
+				}
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, nodes[start:])
+	if len(parts) < 2 {
+		return nodeListToBlocks(parts[0], source)
+	}
+
+	columns := make([]Block, len(parts))
+	for i, part := range parts {
+		columns[i] = Block{Type: "column", Children: nodeListToBlocks(part, source)}
+		if len(columns[i].Children) == 0 {
+			// A column can't be created empty
+			columns[i].Children = []Block{newTextBlock("paragraph", nil)}
+		}
+	}
+	return []Block{{Type: "column_list", Children: columns}}
+}
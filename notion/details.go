@@ -0,0 +1,159 @@
+package notion
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Toggles are written as HTML <details> elements, which GitHub and most
+// markdown viewers render as collapsible sections:
+//
+//	<details>
+//	<summary>Toggle text</summary>
+//
+//	Nested markdown
+//
+//	</details>
+//
+// A toggleable heading wraps its text in <h1>-<h3> inside the summary.
+
+// detailsMarkdown writes a toggle with the given summary markup and
+// children.
+func detailsMarkdown(summary string, children []Block, trailingChildPages map[string]bool) string {
+	var b strings.Builder
+	b.WriteString("<details>\n<summary>" + summary + "</summary>\n")
+	if md := strings.TrimRight(BlocksToMarkdownWithChildPages(children, trailingChildPages), "\n"); md != "" {
+		// The blank lines end the HTML block, so the content is read as markdown
+		b.WriteString("\n" + md + "\n\n")
+	}
+	b.WriteString("</details>\n\n")
+	return b.String()
+}
+
+// headingSummary returns the summary markup for a toggleable heading.
+func headingSummary(level int, text string) string {
+	return fmt.Sprintf("<h%d>%s</h%d>", level, text, level)
+}
+
+// detailsToBlock reads the opening of a <details> element from an HTML
+// block: the <details> tag and the <summary>. It returns a toggle, or a
+// toggleable heading if the summary is a heading. Anything after the
+// summary in the same HTML block becomes the first children. closed
+// reports whether the HTML block also holds the closing </details>.
+func detailsToBlock(raw string) (block Block, closed bool, ok bool) {
+	rest := strings.TrimSpace(raw)
+	if !strings.HasPrefix(rest, "<details") || len(rest) == len("<details") {
+		return Block{}, false, false
+	}
+	// "<details>" or "<details open>", not "<detailsfoo>"
+	if c := rest[len("<details")]; c != '>' && c != ' ' && c != '\t' && c != '\n' {
+		return Block{}, false, false
+	}
+	rest = strings.TrimSpace(rest[strings.IndexByte(rest, '>')+1:])
+
+	block = newTextBlock("toggle", nil)
+	if strings.HasPrefix(rest, "<summary>") {
+		rest = rest[len("<summary>"):]
+		end := summaryEnd(rest)
+		if end < 0 {
+			end = len(rest)
+		}
+		block = summaryToBlock(rest[:end])
+		rest = strings.TrimPrefix(rest[end:], "</summary>")
+	}
+
+	rest = strings.TrimSpace(rest)
+	if strings.HasSuffix(rest, "</details>") {
+		closed = true
+		rest = strings.TrimSpace(strings.TrimSuffix(rest, "</details>"))
+	}
+	if rest != "" {
+		block.Children = parseNested(rest)
+	}
+	return block, closed, true
+}
+
+// summaryEnd returns the index of the </summary> tag that closes s, skipping
+// escaped ones that are part of the text.
+func summaryEnd(s string) int {
+	for from := 0; ; {
+		i := strings.Index(s[from:], "</summary>")
+		if i < 0 {
+			return -1
+		}
+		i += from
+		if i == 0 || s[i-1] != '\\' {
+			return i
+		}
+		from = i + 1
+	}
+}
+
+// summaryToBlock converts the content of a <summary> to a toggle, or to a
+// toggleable heading if it is wrapped in <h1> to <h6>.
+func summaryToBlock(summary string) Block {
+	summary = strings.TrimSpace(summary)
+	for level := 1; level <= 6; level++ {
+		open, close := fmt.Sprintf("<h%d>", level), fmt.Sprintf("</h%d>", level)
+		if strings.HasPrefix(summary, open) && strings.HasSuffix(summary, close) && len(summary) >= len(open)+len(close) {
+			blockType := "heading_3"
+			if level < 3 {
+				blockType = fmt.Sprintf("heading_%d", level)
+			}
+			block := newTextBlock(blockType, nil)
+			block.RichText, block.Color = inlineMarkdownToRichText(summary[len(open) : len(summary)-len(close)])
+			block.IsToggleable = true
+			return block
+		}
+	}
+	block := newTextBlock("toggle", nil)
+	block.RichText, block.Color = inlineMarkdownToRichText(summary)
+	return block
+}
+
+// inlineMarkdownToRichText parses text written with inline markdown, such
+// as the content of an HTML tag, into rich text. A {color=...} list at the
+// end is returned as the block color.
+func inlineMarkdownToRichText(s string) ([]RichText, string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, ""
+	}
+	source := []byte(s)
+	doc := markdownParser.Parse(text.NewReader(source))
+	if p := doc.FirstChild(); p != nil && p.Kind() == ast.KindParagraph && p.NextSibling() == nil {
+		color := cutBlockColor(p, source)
+		return inlineToRichText(p, source), color
+	}
+	return plainRichText(s), ""
+}
+
+// detailsEnd returns the index of the HTML block that closes a <details>
+// element whose content starts at nodes[from], or len(nodes) if it is
+// never closed. Nested <details> elements are skipped over.
+func detailsEnd(nodes []ast.Node, from int, source []byte) int {
+	depth := 1
+	for i := from; i < len(nodes); i++ {
+		html, ok := nodes[i].(*ast.HTMLBlock)
+		if !ok {
+			continue
+		}
+		raw := htmlBlockText(html, source)
+		if _, closed, ok := detailsToBlock(raw); ok {
+			if !closed {
+				depth++
+			}
+			continue
+		}
+		if strings.TrimSpace(raw) == "</details>" {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(nodes)
+}
@@ -0,0 +1,214 @@
+package notion
+
+import "strings"
+
+// textEscaper escapes plain text so that markdown reads it back as the same
+// text. It tracks whether the output is at the start of a line, where
+// characters like "#", "-" and "1." start blocks.
+type textEscaper struct {
+	lineStart bool
+	// table escapes "|" and writes line breaks as <br>, since a table row
+	// must stay on one line.
+	table bool
+	// dollars escapes "$", for text where dollar signs would otherwise
+	// form an inline equation.
+	dollars bool
+}
+
+// escape returns s with markdown-significant characters backslash-escaped.
+// Characters are only escaped where they could be read as syntax: "_" at
+// word boundaries, "<" before something that looks like a tag, "&" before
+// something that looks like an entity.
+func (e *textEscaper) escape(s string) string {
+	var b strings.Builder
+	escapeAt := -1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c == '\n' {
+			if e.table {
+				b.WriteString("<br>")
+			} else {
+				b.WriteByte('\n')
+				e.lineStart = true
+				// A blank line would end the paragraph; a lone backslash is
+				// a hard line break instead
+				if i+1 < len(s) && s[i+1] == '\n' {
+					b.WriteByte('\\')
+				}
+			}
+			continue
+		}
+
+		if e.lineStart && !e.table {
+			e.lineStart = false
+			// Leading whitespace would be stripped, or start an indented code block
+			if c == ' ' || c == '\t' {
+				b.WriteString(whitespaceEntity(c))
+				continue
+			}
+			line := s[i:]
+			if end := strings.IndexByte(line, '\n'); end >= 0 {
+				line = line[:end]
+			}
+			if at := blockMarkerAt(line); at >= 0 {
+				escapeAt = i + at
+			}
+		}
+
+		// Whitespace before a line break would be trimmed
+		if (c == ' ' || c == '\t') && i+1 < len(s) && s[i+1] == '\n' && !e.table {
+			b.WriteString(whitespaceEntity(c))
+			continue
+		}
+
+		if i == escapeAt || needsEscape(s, i, e.table) || (c == '$' && e.dollars) {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func whitespaceEntity(c byte) string {
+	if c == '\t' {
+		return "&#9;"
+	}
+	return "&#32;"
+}
+
+// needsEscape reports whether s[i] must be escaped wherever it appears.
+func needsEscape(s string, i int, table bool) bool {
+	switch s[i] {
+	case '*', '`', '[', ']', '~':
+		return true
+	case '|':
+		return table
+	case '_':
+		// Intraword underscores never form emphasis. Underscores next to
+		// each other are escaped too, since an escaped one is punctuation.
+		return i == 0 || i == len(s)-1 || !isAlnum(s[i-1]) || !isAlnum(s[i+1])
+	case '\\':
+		// Before a line break it would be a hard break; before whitespace it
+		// may end up in front of an entity
+		return i == len(s)-1 || strings.IndexByte("\n \t", s[i+1]) >= 0 || isASCIIPunct(s[i+1])
+	case '<':
+		if i+1 < len(s) {
+			n := s[i+1]
+			return n == '/' || n == '!' || n == '?' || (n|0x20 >= 'a' && n|0x20 <= 'z')
+		}
+	case '&':
+		return looksLikeEntity(s[i:])
+	}
+	return false
+}
+
+// blockMarkerAt returns the index of the character to escape so that line,
+// at the start of a line, isn't read as a block marker, or -1 if it's fine
+// as is.
+func blockMarkerAt(line string) int {
+	if line == "" {
+		return -1
+	}
+	// Table delimiter row, which would turn the previous line into a table
+	if strings.Trim(line, "|-: \t") == "" && strings.Contains(line, "-") && strings.ContainsAny(line, "|:") {
+		return 0
+	}
+
+	switch c := line[0]; c {
+	case '#', '>':
+		return 0
+	case '$':
+		// Equation block
+		if strings.HasPrefix(line, "$$") {
+			return 0
+		}
+	case '-', '+':
+		// List item, or a thematic break or setext underline
+		if len(line) == 1 || line[1] == ' ' || line[1] == '\t' || isRuleLine(line, c) {
+			return 0
+		}
+	case '=':
+		if isRuleLine(line, c) {
+			return 0
+		}
+	}
+
+	// Ordered list item: up to 9 digits followed by "." or ")"
+	digits := 0
+	for digits < len(line) && digits < 10 && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits <= 9 && digits < len(line) && (line[digits] == '.' || line[digits] == ')') {
+		if digits+1 == len(line) || line[digits+1] == ' ' || line[digits+1] == '\t' {
+			return digits
+		}
+	}
+	return -1
+}
+
+// isRuleLine reports whether line consists only of c and whitespace.
+func isRuleLine(line string, c byte) bool {
+	return strings.Trim(line, string(c)+" \t") == ""
+}
+
+// looksLikeEntity reports whether s starts with an HTML character
+// reference such as "&amp;" or "&#35;".
+func looksLikeEntity(s string) bool {
+	end := strings.IndexByte(s, ';')
+	if end < 2 || end > 32 {
+		return false
+	}
+	name := s[1:end]
+	if name[0] == '#' {
+		name = strings.TrimPrefix(strings.TrimPrefix(name[1:], "x"), "X")
+		return name != "" && strings.Trim(name, "0123456789abcdefABCDEF") == ""
+	}
+	for i := 0; i < len(name); i++ {
+		if !isWordChar(name[i]) || name[i] == '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlnum reports whether c is a letter or digit, counting all non-ASCII
+// bytes as letters.
+func isAlnum(c byte) bool {
+	return c != '_' && isWordChar(c)
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= 0x80 || (c >= '0' && c <= '9') || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
+
+// isASCIIPunct reports whether c can be backslash-escaped in CommonMark.
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// escapeClosingHashes escapes trailing "#" characters in heading text,
+// which would otherwise be read as an optional closing sequence.
+func escapeClosingHashes(text string) string {
+	trimmed := strings.TrimRight(text, "#")
+	if trimmed == text || (!strings.HasSuffix(trimmed, " ") && !strings.HasSuffix(trimmed, "\t")) {
+		return text
+	}
+	return trimmed + `\` + text[len(trimmed):]
+}
+
+// escapeLinkText escapes text inside [...], such as a page mention title.
+func escapeLinkText(s string) string {
+	e := &textEscaper{dollars: mayFormMath(s)}
+	return e.escape(s)
+}
+
+// linkDestination formats a URL for use in (...). URLs with spaces or
+// parentheses are wrapped in <...>.
+func linkDestination(url string) string {
+	if !strings.ContainsAny(url, " ()<>\\") {
+		return url
+	}
+	r := strings.NewReplacer(`\`, `\\`, "<", `\<`, ">", `\>`)
+	return "<" + r.Replace(url) + ">"
+}
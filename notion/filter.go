@@ -0,0 +1,788 @@
+package notion
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CompileWhere compiles a filter expression into a native Notion filter object.
+//
+// The expression language is a small boolean DSL:
+//
+//	Status = "Active" AND Priority in ("P0", "P1") AND Due < today+7d
+//	NOT (Tags contains "archived") OR `Due Date` is empty
+//
+// Property names are bare words, or quoted with backticks or double quotes
+// when they contain spaces. Every comparison is type-checked against the
+// database schema so the right property filter kind (select, status, date,
+// ...) is chosen. Relative dates (today, now, yesterday, tomorrow, with
+// optional +/-Nd, Nw, Nm or Ny offsets) are resolved against now.
+func CompileWhere(where string, schema []SchemaProperty, now time.Time) (map[string]any, error) {
+	tokens, err := lexWhere(where)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]SchemaProperty)
+	for _, p := range schema {
+		props[p.Name] = p
+	}
+
+	p := &whereParser{input: where, tokens: tokens, props: props, now: now}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorAt(tok, "unexpected token")
+	}
+
+	filter, err := node.compile(false)
+	if fe, ok := err.(*FilterError); ok && fe.Input == "" {
+		fe.Input = where
+	}
+	return filter, err
+}
+
+// FilterError is a parse or type error in a where expression.
+// Pos is the byte offset of the offending token.
+type FilterError struct {
+	Input string
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%s at position %d near %q\n  %s\n  %s^",
+		e.Msg, e.Pos+1, e.Token, e.Input, strings.Repeat(" ", e.Pos))
+}
+
+// Lexer
+
+type whereTokenKind int
+
+const (
+	tokEOF whereTokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDate
+	tokDuration
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type whereToken struct {
+	kind whereTokenKind
+	text string // Raw text (unquoted for strings)
+	pos  int
+}
+
+var (
+	whereDateLiteral = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(T[0-9:.]+(Z|[+-]\d{2}:\d{2})?)?`)
+	whereDuration    = regexp.MustCompile(`^\d+[dwmy]\b`)
+	whereNumber      = regexp.MustCompile(`^-?\d+(\.\d+)?`)
+	whereUUID        = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+)
+
+func lexWhere(input string) ([]whereToken, error) {
+	var tokens []whereToken
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, whereToken{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, whereToken{tokRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, whereToken{tokComma, ",", i})
+			i++
+		case c == '"' || c == '\'' || c == '`':
+			var sb strings.Builder
+			j := i + 1
+			for j < len(input) && input[j] != c {
+				if input[j] == '\\' && j+1 < len(input) {
+					j++
+				}
+				sb.WriteByte(input[j])
+				j++
+			}
+			if j >= len(input) {
+				return nil, &FilterError{Input: input, Pos: i, Token: input[i:], Msg: "unterminated quoted string"}
+			}
+			kind := tokString
+			if c == '`' {
+				kind = tokIdent
+			}
+			tokens = append(tokens, whereToken{kind, sb.String(), i})
+			i = j + 1
+		case strings.ContainsRune("=!<>+-", rune(c)):
+			if c == '-' && i+1 < len(input) && input[i+1] >= '0' && input[i+1] <= '9' && !prevIsValue(tokens) {
+				m := whereNumber.FindString(input[i:])
+				tokens = append(tokens, whereToken{tokNumber, m, i})
+				i += len(m)
+				continue
+			}
+			op := string(c)
+			if i+1 < len(input) && input[i+1] == '=' && c != '+' && c != '-' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &FilterError{Input: input, Pos: i, Token: op, Msg: "unexpected character"}
+			}
+			tokens = append(tokens, whereToken{tokOp, op, i})
+			i += len(op)
+		case c >= '0' && c <= '9':
+			if m := whereDateLiteral.FindString(input[i:]); m != "" {
+				tokens = append(tokens, whereToken{tokDate, m, i})
+				i += len(m)
+			} else if m := whereDuration.FindString(input[i:]); m != "" {
+				tokens = append(tokens, whereToken{tokDuration, m, i})
+				i += len(m)
+			} else {
+				m := whereNumber.FindString(input[i:])
+				tokens = append(tokens, whereToken{tokNumber, m, i})
+				i += len(m)
+			}
+		case c == '_' || unicode.IsLetter(rune(c)) || c >= 0x80:
+			j := i
+			for j < len(input) {
+				r := rune(input[j])
+				if r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) || input[j] >= 0x80 {
+					j++
+					continue
+				}
+				break
+			}
+			tokens = append(tokens, whereToken{tokIdent, input[i:j], i})
+			i = j
+		default:
+			return nil, &FilterError{Input: input, Pos: i, Token: string(c), Msg: "unexpected character"}
+		}
+	}
+	tokens = append(tokens, whereToken{tokEOF, "", len(input)})
+	return tokens, nil
+}
+
+// prevIsValue reports whether the previous token ends a value, in which case
+// a following '-' is a date offset operator rather than a negative sign.
+func prevIsValue(tokens []whereToken) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[len(tokens)-1].kind {
+	case tokIdent, tokString, tokNumber, tokDate, tokDuration, tokRParen:
+		return true
+	}
+	return false
+}
+
+// Parser
+
+type whereParser struct {
+	input  string
+	tokens []whereToken
+	pos    int
+	props  map[string]SchemaProperty
+	now    time.Time
+}
+
+func (p *whereParser) peek() whereToken { return p.tokens[p.pos] }
+
+func (p *whereParser) next() whereToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *whereParser) errorAt(tok whereToken, format string, args ...any) error {
+	text := tok.text
+	if tok.kind == tokEOF {
+		text = "end of input"
+	}
+	return &FilterError{Input: p.input, Pos: tok.pos, Token: text, Msg: fmt.Sprintf(format, args...)}
+}
+
+// isKeyword reports whether tok is the given case-insensitive keyword.
+func isKeyword(tok whereToken, kw string) bool {
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, kw)
+}
+
+func (p *whereParser) parseOr() (whereNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []whereNode{left}
+	for isKeyword(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return &compoundNode{op: "or", children: nodes}, nil
+}
+
+func (p *whereParser) parseAnd() (whereNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []whereNode{left}
+	for isKeyword(p.peek(), "and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return &compoundNode{op: "and", children: nodes}, nil
+}
+
+func (p *whereParser) parseUnary() (whereNode, error) {
+	tok := p.peek()
+	if isKeyword(tok, "not") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner: inner}, nil
+	}
+	if tok.kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorAt(closing, "expected ')'")
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *whereParser) parseComparison() (whereNode, error) {
+	propTok := p.next()
+	if propTok.kind != tokIdent && propTok.kind != tokString {
+		return nil, p.errorAt(propTok, "expected property name")
+	}
+	prop, ok := p.props[propTok.text]
+	if !ok {
+		return nil, p.errorAt(propTok, "unknown property%s", p.suggestProperty(propTok.text))
+	}
+
+	opTok := p.next()
+	cmp := &compareNode{prop: prop, propTok: propTok, opTok: opTok}
+
+	switch {
+	case opTok.kind == tokOp:
+		switch opTok.text {
+		case "=":
+			cmp.op = "eq"
+		case "!=":
+			cmp.op = "ne"
+		case "<":
+			cmp.op = "lt"
+		case "<=":
+			cmp.op = "le"
+		case ">":
+			cmp.op = "gt"
+		case ">=":
+			cmp.op = "ge"
+		default:
+			return nil, p.errorAt(opTok, "expected comparison operator")
+		}
+	case isKeyword(opTok, "contains"):
+		cmp.op = "contains"
+	case isKeyword(opTok, "starts_with"):
+		cmp.op = "starts_with"
+	case isKeyword(opTok, "ends_with"):
+		cmp.op = "ends_with"
+	case isKeyword(opTok, "in"):
+		cmp.op = "in"
+	case isKeyword(opTok, "is"):
+		negated := false
+		if isKeyword(p.peek(), "not") {
+			p.next()
+			negated = true
+		}
+		if emptyTok := p.next(); !isKeyword(emptyTok, "empty") {
+			return nil, p.errorAt(emptyTok, "expected 'empty'")
+		}
+		cmp.op = "empty"
+		if err := p.checkComparison(cmp); err != nil {
+			return nil, err
+		}
+		if negated {
+			return &notNode{inner: cmp}, nil
+		}
+		return cmp, nil
+	case isKeyword(opTok, "not"):
+		// "not contains" / "not in"
+		inner := p.next()
+		switch {
+		case isKeyword(inner, "contains"):
+			cmp.op = "contains"
+		case isKeyword(inner, "in"):
+			cmp.op = "in"
+		default:
+			return nil, p.errorAt(inner, "expected 'contains' or 'in' after 'not'")
+		}
+		if err := p.parseComparisonValues(cmp); err != nil {
+			return nil, err
+		}
+		return &notNode{inner: cmp}, nil
+	default:
+		return nil, p.errorAt(opTok, "expected comparison operator")
+	}
+
+	if err := p.parseComparisonValues(cmp); err != nil {
+		return nil, err
+	}
+	return cmp, nil
+}
+
+// parseComparisonValues parses the right-hand side of a comparison and type-checks it.
+func (p *whereParser) parseComparisonValues(cmp *compareNode) error {
+	if cmp.op == "in" {
+		if open := p.next(); open.kind != tokLParen {
+			return p.errorAt(open, "expected '(' after 'in'")
+		}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return err
+			}
+			cmp.values = append(cmp.values, v)
+			sep := p.next()
+			if sep.kind == tokRParen {
+				break
+			}
+			if sep.kind != tokComma {
+				return p.errorAt(sep, "expected ',' or ')'")
+			}
+		}
+	} else {
+		v, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		cmp.values = []whereValue{v}
+	}
+	return p.checkComparison(cmp)
+}
+
+// whereValue is a literal on the right-hand side of a comparison.
+type whereValue struct {
+	kind string // string, number, bool, date
+	val  any
+	tok  whereToken
+}
+
+func (p *whereParser) parseValue() (whereValue, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return whereValue{kind: "string", val: tok.text, tok: tok}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return whereValue{}, p.errorAt(tok, "invalid number")
+		}
+		return whereValue{kind: "number", val: n, tok: tok}, nil
+	case tokDate:
+		return whereValue{kind: "date", val: tok.text, tok: tok}, nil
+	case tokIdent:
+		switch strings.ToLower(tok.text) {
+		case "true", "false":
+			return whereValue{kind: "bool", val: strings.EqualFold(tok.text, "true"), tok: tok}, nil
+		case "today", "now", "yesterday", "tomorrow":
+			return p.parseRelativeDate(tok)
+		}
+		return whereValue{}, p.errorAt(tok, "expected a value (strings must be quoted)")
+	}
+	return whereValue{}, p.errorAt(tok, "expected a value")
+}
+
+// parseRelativeDate resolves today/now/yesterday/tomorrow with an optional offset.
+func (p *whereParser) parseRelativeDate(tok whereToken) (whereValue, error) {
+	base := p.now
+	switch strings.ToLower(tok.text) {
+	case "yesterday":
+		base = base.AddDate(0, 0, -1)
+	case "tomorrow":
+		base = base.AddDate(0, 0, 1)
+	}
+
+	if op := p.peek(); op.kind == tokOp && (op.text == "+" || op.text == "-") {
+		p.next()
+		durTok := p.next()
+		if durTok.kind != tokDuration {
+			return whereValue{}, p.errorAt(durTok, "expected a duration such as 7d, 2w, 1m or 1y")
+		}
+		n, _ := strconv.Atoi(durTok.text[:len(durTok.text)-1])
+		if op.text == "-" {
+			n = -n
+		}
+		switch durTok.text[len(durTok.text)-1] {
+		case 'd':
+			base = base.AddDate(0, 0, n)
+		case 'w':
+			base = base.AddDate(0, 0, 7*n)
+		case 'm':
+			base = base.AddDate(0, n, 0)
+		case 'y':
+			base = base.AddDate(n, 0, 0)
+		}
+	}
+
+	if strings.EqualFold(tok.text, "now") {
+		return whereValue{kind: "date", val: base.Format(time.RFC3339), tok: tok}, nil
+	}
+	return whereValue{kind: "date", val: base.Format("2006-01-02"), tok: tok}, nil
+}
+
+// suggestProperty returns a hint listing a property with a similar name, if any.
+func (p *whereParser) suggestProperty(name string) string {
+	lower := strings.ToLower(name)
+	for candidate := range p.props {
+		if strings.ToLower(candidate) == lower {
+			return fmt.Sprintf(" (did you mean %q?)", candidate)
+		}
+	}
+	for candidate := range p.props {
+		lc := strings.ToLower(candidate)
+		if strings.HasPrefix(lc, lower) || strings.HasPrefix(lower, lc) {
+			return fmt.Sprintf(" (did you mean %q?)", candidate)
+		}
+	}
+	return ""
+}
+
+// Type checking
+
+// filterKind returns the Notion filter condition key for a property type.
+func filterKind(propType string) string {
+	switch propType {
+	case "title", "rich_text", "url", "email", "phone_number":
+		return "text"
+	case "created_time", "last_edited_time", "date":
+		return "date"
+	case "people", "created_by", "last_edited_by":
+		return "people"
+	}
+	return propType
+}
+
+// allowedOps lists the operators each filter kind supports, and the value
+// kinds it accepts.
+var allowedOps = map[string]struct {
+	ops    string
+	values string
+}{
+	"text":         {"eq ne contains starts_with ends_with in empty", "string"},
+	"number":       {"eq ne lt le gt ge in empty", "number"},
+	"checkbox":     {"eq ne", "bool"},
+	"select":       {"eq ne in empty", "string"},
+	"status":       {"eq ne in empty", "string"},
+	"multi_select": {"eq contains in empty", "string"},
+	"date":         {"eq ne lt le gt ge empty", "date"},
+	"people":       {"eq contains in empty", "string"},
+	"relation":     {"eq contains in empty", "string"},
+	"files":        {"empty", ""},
+	"unique_id":    {"eq ne lt le gt ge in", "number"},
+	"formula":      {"eq ne lt le gt ge contains starts_with ends_with in empty", "string number bool date"},
+}
+
+func (p *whereParser) checkComparison(cmp *compareNode) error {
+	kind := filterKind(cmp.prop.Type)
+	allowed, ok := allowedOps[kind]
+	if !ok {
+		return p.errorAt(cmp.propTok, "filtering on %s properties is not supported", cmp.prop.Type)
+	}
+	if !containsWord(allowed.ops, cmp.op) {
+		return p.errorAt(cmp.opTok, "operator not supported for %s property %q", cmp.prop.Type, cmp.prop.Name)
+	}
+
+	for i, v := range cmp.values {
+		// Dates may also be written as quoted strings.
+		if v.kind == "string" && kind == "date" {
+			if !whereDateLiteral.MatchString(v.val.(string)) {
+				return p.errorAt(v.tok, "expected a date (YYYY-MM-DD or today+Nd) for %s property %q", cmp.prop.Type, cmp.prop.Name)
+			}
+			cmp.values[i].kind = "date"
+			continue
+		}
+		// Unique IDs may be written with their prefix, e.g. "TASK-12".
+		if v.kind == "string" && kind == "unique_id" {
+			s := v.val.(string)
+			n, err := strconv.ParseFloat(s[strings.LastIndex(s, "-")+1:], 64)
+			if err != nil {
+				return p.errorAt(v.tok, "expected a unique ID number for property %q", cmp.prop.Name)
+			}
+			cmp.values[i] = whereValue{kind: "number", val: n, tok: v.tok}
+			continue
+		}
+		// People and relations match by ID, not by name or title
+		if v.kind == "string" && (kind == "people" || kind == "relation") && !whereUUID.MatchString(v.val.(string)) {
+			what := "user"
+			if kind == "relation" {
+				what = "page"
+			}
+			return p.errorAt(v.tok, "expected a %s ID for %s property %q", what, cmp.prop.Type, cmp.prop.Name)
+		}
+		if !containsWord(allowed.values, v.kind) {
+			return p.errorAt(v.tok, "expected %s value for %s property %q, got %s", allowed.values, cmp.prop.Type, cmp.prop.Name, v.kind)
+		}
+	}
+
+	if kind == "formula" && len(cmp.values) > 0 {
+		first := cmp.values[0].kind
+		for _, v := range cmp.values[1:] {
+			if v.kind != first {
+				return p.errorAt(v.tok, "mixed value types in formula comparison")
+			}
+		}
+	}
+	return nil
+}
+
+func containsWord(list, word string) bool {
+	for _, w := range strings.Fields(list) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// AST and compilation
+
+type whereNode interface {
+	// compile returns the Notion filter for the node, negated if negate is true.
+	compile(negate bool) (map[string]any, error)
+}
+
+type compoundNode struct {
+	op       string // and, or
+	children []whereNode
+}
+
+type notNode struct {
+	inner whereNode
+}
+
+type compareNode struct {
+	prop    SchemaProperty
+	propTok whereToken
+	opTok   whereToken
+	op      string // eq, ne, lt, le, gt, ge, contains, starts_with, ends_with, in, empty
+	values  []whereValue
+}
+
+func (n *compoundNode) compile(negate bool) (map[string]any, error) {
+	op := n.op
+	if negate {
+		// De Morgan: NOT (a AND b) == NOT a OR NOT b
+		if op == "and" {
+			op = "or"
+		} else {
+			op = "and"
+		}
+	}
+	var filters []map[string]any
+	for _, child := range n.children {
+		f, err := child.compile(negate)
+		if err != nil {
+			return nil, err
+		}
+		// Flatten nested compounds of the same kind to keep the filter shallow;
+		// Notion only allows two levels of nesting.
+		if nested, ok := f[op].([]map[string]any); ok && len(f) == 1 {
+			filters = append(filters, nested...)
+		} else {
+			filters = append(filters, f)
+		}
+	}
+	return map[string]any{op: filters}, nil
+}
+
+func (n *notNode) compile(negate bool) (map[string]any, error) {
+	return n.inner.compile(!negate)
+}
+
+// negatedOps maps each operator to its negation. Operators missing from the
+// map (starts_with, ends_with) cannot be negated in a Notion filter.
+var negatedOps = map[string]string{
+	"eq":        "ne",
+	"ne":        "eq",
+	"lt":        "ge",
+	"ge":        "lt",
+	"gt":        "le",
+	"le":        "gt",
+	"contains":  "not_contains",
+	"empty":     "not_empty",
+	"not_empty": "empty",
+}
+
+func (n *compareNode) compile(negate bool) (map[string]any, error) {
+	if n.op == "in" {
+		// "x in (a, b)" is "x = a OR x = b"; negated it becomes "x != a AND x != b".
+		var filters []map[string]any
+		for _, v := range n.values {
+			single := &compareNode{prop: n.prop, propTok: n.propTok, opTok: n.opTok, op: "eq", values: []whereValue{v}}
+			f, err := single.compile(negate)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, f)
+		}
+		if len(filters) == 1 {
+			return filters[0], nil
+		}
+		if negate {
+			return map[string]any{"and": filters}, nil
+		}
+		return map[string]any{"or": filters}, nil
+	}
+
+	op := n.op
+	if negate {
+		neg, ok := negatedOps[op]
+		if !ok {
+			return nil, &FilterError{Pos: n.opTok.pos, Token: n.opTok.text, Msg: fmt.Sprintf("%s cannot be negated", n.opTok.text)}
+		}
+		op = neg
+	}
+
+	kind := filterKind(n.prop.Type)
+	var value any
+	valueKind := ""
+	if len(n.values) > 0 {
+		value = n.values[0].val
+		valueKind = n.values[0].kind
+	}
+
+	// Multi-valued properties match single values with contains.
+	if kind == "multi_select" || kind == "people" || kind == "relation" {
+		switch op {
+		case "eq":
+			op = "contains"
+		case "ne":
+			op = "not_contains"
+		}
+	}
+
+	// Dates have no "does not equal"; express it as before OR after.
+	if kind == "date" && op == "ne" {
+		return map[string]any{"or": []map[string]any{
+			n.condition("lt", value, valueKind),
+			n.condition("gt", value, valueKind),
+		}}, nil
+	}
+	if kind == "formula" && valueKind == "date" && op == "ne" {
+		return map[string]any{"or": []map[string]any{
+			n.condition("lt", value, valueKind),
+			n.condition("gt", value, valueKind),
+		}}, nil
+	}
+
+	return n.condition(op, value, valueKind), nil
+}
+
+// condition builds a single property filter.
+func (n *compareNode) condition(op string, value any, valueKind string) map[string]any {
+	kind := filterKind(n.prop.Type)
+	cond := map[string]any{}
+	name := notionFilterOp(op, kind, valueKind)
+	switch op {
+	case "empty", "not_empty":
+		cond[name] = true
+	default:
+		cond[name] = value
+	}
+
+	filter := map[string]any{"property": n.prop.Name}
+	if kind == "formula" {
+		formulaKind := map[string]string{"string": "string", "number": "number", "bool": "checkbox", "date": "date"}[valueKind]
+		if formulaKind == "" {
+			formulaKind = "string"
+		}
+		filter["formula"] = map[string]any{formulaKind: cond}
+		return filter
+	}
+	// Every other property filters under its own type key.
+	filter[n.prop.Type] = cond
+	return filter
+}
+
+// notionFilterOp returns the Notion condition name for an operator.
+func notionFilterOp(op, kind, valueKind string) string {
+	isDate := kind == "date" || (kind == "formula" && valueKind == "date")
+	if isDate {
+		switch op {
+		case "eq":
+			return "equals"
+		case "lt":
+			return "before"
+		case "gt":
+			return "after"
+		case "le":
+			return "on_or_before"
+		case "ge":
+			return "on_or_after"
+		}
+	}
+	switch op {
+	case "eq":
+		return "equals"
+	case "ne":
+		return "does_not_equal"
+	case "lt":
+		return "less_than"
+	case "le":
+		return "less_than_or_equal_to"
+	case "gt":
+		return "greater_than"
+	case "ge":
+		return "greater_than_or_equal_to"
+	case "contains":
+		return "contains"
+	case "not_contains":
+		return "does_not_contain"
+	case "starts_with":
+		return "starts_with"
+	case "ends_with":
+		return "ends_with"
+	case "empty":
+		return "is_empty"
+	case "not_empty":
+		return "is_not_empty"
+	}
+	return op
+}
@@ -0,0 +1,603 @@
+package notion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// inlineStyle is the formatting in effect while walking inline nodes.
+type inlineStyle struct {
+	bold, italic, strikethrough, underline, code bool
+	link                                         string
+	color                                        string // Notion color, such as "red" or "red_background"
+}
+
+func (s inlineStyle) annotations() *Annotations {
+	if !s.bold && !s.italic && !s.strikethrough && !s.underline && !s.code && s.color == "" {
+		return nil
+	}
+	return &Annotations{Bold: s.bold, Italic: s.italic, Strikethrough: s.strikethrough, Underline: s.underline, Code: s.code, Color: s.color}
+}
+
+// inlineToRichText converts the inline children of a block node to rich
+// text. Nested formatting is merged, so every run carries all annotations
+// that apply to it and adjacent runs with the same formatting are joined.
+func inlineToRichText(n ast.Node, source []byte) []RichText {
+	return appendChildren(nil, n, source, inlineStyle{})
+}
+
+// appendChildren appends the inline children of n. <u> and color <span>
+// tags among them style the siblings up to their closing tags.
+func appendChildren(result []RichText, n ast.Node, source []byte, style inlineStyle) []RichText {
+	underlines := 0
+	var colors []string // colors in effect before each open <span>
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if html, ok := c.(*ast.RawHTML); ok {
+			tag := rawHTMLText(html, source)
+			if color, ok := parseColorTag(tag); ok {
+				colors = append(colors, style.color)
+				style.color = color
+				continue
+			}
+			switch strings.ToLower(tag) {
+			case "<u>":
+				underlines++
+				style.underline = true
+				continue
+			case "</u>":
+				if underlines > 0 {
+					underlines--
+					style.underline = underlines > 0
+					continue
+				}
+			case "</span>":
+				if len(colors) > 0 {
+					style.color = colors[len(colors)-1]
+					colors = colors[:len(colors)-1]
+					continue
+				}
+			}
+		}
+		result = appendInline(result, c, source, style)
+	}
+	return result
+}
+
+func appendInline(result []RichText, n ast.Node, source []byte, style inlineStyle) []RichText {
+	switch node := n.(type) {
+	case *ast.Text:
+		content := unescapeMarkdown(node.Value(source))
+		if node.SoftLineBreak() || node.HardLineBreak() {
+			content += "\n"
+		}
+		return appendRun(result, content, style)
+
+	case *ast.String:
+		return appendRun(result, unescapeMarkdown(node.Value), style)
+
+	case *ast.CodeSpan:
+		var code strings.Builder
+		for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+			switch t := c.(type) {
+			case *ast.Text:
+				code.Write(t.Value(source))
+			case *ast.String:
+				code.Write(t.Value)
+			}
+		}
+		style.code = true
+		return appendRun(result, code.String(), style)
+
+	case *ast.Emphasis:
+		if node.Level >= 2 {
+			style.bold = true
+		} else {
+			style.italic = true
+		}
+
+	case *extast.Strikethrough:
+		style.strikethrough = true
+
+	case *ast.Link:
+		url := unescapeMarkdown(node.Destination)
+		if pageID, ok := strings.CutPrefix(url, "notion://"); ok {
+			if mention, ok := pageMention(node, source, pageID, style); ok {
+				return append(result, mention)
+			}
+		}
+		style.link = url
+
+	case *ast.AutoLink:
+		url := string(node.URL(source))
+		if node.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(url, "mailto:") {
+			style.link = "mailto:" + url
+		} else {
+			style.link = url
+		}
+		return appendRun(result, string(node.Label(source)), style)
+
+	case *mathInline:
+		return append(result, RichText{
+			Type:        "equation",
+			Equation:    &Equation{Expression: node.expression},
+			Annotations: style.annotations(),
+		})
+
+	case *ast.Image:
+		// Inline images keep their alt text, linked to the image
+		style.link = unescapeMarkdown(node.Destination)
+
+	case *ast.RawHTML:
+		raw := rawHTMLText(node, source)
+		// <br> is how table cells hold line breaks
+		if isLineBreakTag(raw) {
+			return appendRun(result, "\n", style)
+		}
+		// Notion has no other inline HTML; keep the tag as text
+		return appendRun(result, raw, style)
+
+	case *extast.TaskCheckBox:
+		return result
+	}
+
+	return appendChildren(result, n, source, style)
+}
+
+// rawHTMLText returns the source of an inline HTML node.
+func rawHTMLText(n *ast.RawHTML, source []byte) string {
+	var raw strings.Builder
+	for i := 0; i < n.Segments.Len(); i++ {
+		seg := n.Segments.At(i)
+		raw.Write(seg.Value(source))
+	}
+	return raw.String()
+}
+
+func isLineBreakTag(tag string) bool {
+	switch strings.ToLower(strings.ReplaceAll(tag, " ", "")) {
+	case "<br>", "<br/>":
+		return true
+	}
+	return false
+}
+
+// pageMention converts [@Title](notion://page-id) to a page mention. The
+// formatting of the link text applies to the mention.
+func pageMention(link *ast.Link, source []byte, pageID string, style inlineStyle) (RichText, bool) {
+	inner := appendChildren(nil, link, source, style)
+	if len(inner) == 0 || inner[0].Text == nil || !strings.HasPrefix(inner[0].Text.Content, "@") {
+		return RichText{}, false
+	}
+	return RichText{
+		Type:        "mention",
+		Mention:     &Mention{Type: "page", Page: &ObjectRef{ID: pageID}},
+		Annotations: inner[0].Annotations,
+	}, true
+}
+
+// appendRun appends text with the given style, extending the previous run
+// when its formatting is the same.
+func appendRun(result []RichText, content string, style inlineStyle) []RichText {
+	if content == "" {
+		return result
+	}
+	rt := RichText{Type: "text", Text: &Text{Content: content}, Annotations: style.annotations()}
+	if style.link != "" {
+		rt.Text.Link = &Link{URL: style.link}
+	}
+
+	if n := len(result); n > 0 {
+		last := &result[n-1]
+		if last.Type == "text" && last.Text != nil && sameFormatting(*last, rt) {
+			last.Text.Content += content
+			return result
+		}
+	}
+	return append(result, rt)
+}
+
+// sameFormatting reports whether two text runs have the same annotations and link.
+func sameFormatting(a, b RichText) bool {
+	return runStyle(a) == runStyle(b)
+}
+
+// runStyle returns the markdown-relevant formatting of a rich text run.
+func runStyle(rt RichText) inlineStyle {
+	var s inlineStyle
+	if a := rt.Annotations; a != nil {
+		s.bold, s.italic, s.strikethrough, s.underline, s.code = a.Bold, a.Italic, a.Strikethrough, a.Underline, a.Code
+		if a.Color != "default" {
+			s.color = a.Color
+		}
+	}
+	if rt.Type == "text" && rt.Text != nil && rt.Text.Link != nil {
+		s.link = rt.Text.Link.URL
+	} else if rt.Href != "" && rt.Type != "mention" {
+		s.link = rt.Href
+	}
+	return s
+}
+
+// unescapeMarkdown resolves backslash escapes and character references in
+// one pass, so an escaped "\&amp;" stays "&amp;".
+func unescapeMarkdown(b []byte) string {
+	var out []byte
+	start := 0
+	for i := 0; i < len(b)-1; i++ {
+		if b[i] == '\\' && isASCIIPunct(b[i+1]) {
+			out = append(out, resolveReferences(b[start:i])...)
+			out = append(out, b[i+1])
+			i++
+			start = i + 1
+		}
+	}
+	out = append(out, resolveReferences(b[start:])...)
+	return string(out)
+}
+
+func resolveReferences(b []byte) []byte {
+	return util.ResolveEntityNames(util.ResolveNumericReferences(b))
+}
+
+// inlineLayer is one level of markdown inline nesting.
+type inlineLayer int
+
+const (
+	layerLink inlineLayer = iota
+	layerBold
+	layerItalic
+	layerStrikethrough
+	layerUnderline
+	layerColor
+	layerCode
+	layerCount
+)
+
+func (s inlineStyle) has(l inlineLayer) bool {
+	switch l {
+	case layerLink:
+		return s.link != ""
+	case layerBold:
+		return s.bold
+	case layerItalic:
+		return s.italic
+	case layerStrikethrough:
+		return s.strikethrough
+	case layerUnderline:
+		return s.underline
+	case layerColor:
+		return s.color != ""
+	case layerCode:
+		return s.code
+	}
+	return false
+}
+
+// inlineRun is a piece of inline output: text in a style, or markdown
+// that is already rendered (an atom, such as a page mention).
+type inlineRun struct {
+	text  string
+	atom  bool
+	style inlineStyle
+}
+
+// richTextToMarkdown converts Notion rich_text array to markdown string,
+// preserving formatting, links, and mentions.
+//
+// Formatting is written as nested markers rather than per run, so
+// overlapping styles produce "**a *b* c**" instead of "**a** ***b*** **c**".
+// Whitespace at the edges of a bold, italic, struck through or linked run
+// is moved outside the markers that end there, since CommonMark doesn't
+// recognize "** a**" as bold. Code spans and the HTML tags of underlines
+// and colors keep it inside, where it stays styled.
+func richTextToMarkdown(richText []RichText) string {
+	return renderRichText(richText, &textEscaper{lineStart: true})
+}
+
+// tableCellMarkdown converts the rich text of a table cell, which must not
+// contain unescaped pipes or line breaks.
+func tableCellMarkdown(richText []RichText) string {
+	return renderRichText(richText, &textEscaper{table: true})
+}
+
+func renderRichText(richText []RichText, esc *textEscaper) string {
+	// Dollar signs are escaped only where they could read as an equation
+	esc.dollars = esc.dollars || mayFormMath(richTextPlain(richText))
+
+	var runs []inlineRun
+	for _, rt := range mergeRuns(richText) {
+		switch rt.Type {
+		case "equation":
+			if rt.Equation != nil {
+				style := runStyle(rt)
+				style.code = false
+				runs = append(runs, inlineRun{text: inlineMathMarkdown(rt.Equation.Expression), atom: true, style: style})
+			}
+		case "mention":
+			style := runStyle(rt)
+			style.code = false
+			runs = append(runs, inlineRun{text: mentionMarkdown(rt), atom: true, style: style})
+		case "text":
+			content := rt.PlainText
+			if rt.Text != nil {
+				content = rt.Text.Content
+			}
+			style := runStyle(rt)
+			if isAutolink(content, style.link) {
+				style.link = ""
+				runs = append(runs, inlineRun{text: "<" + content + ">", atom: true, style: style})
+				continue
+			}
+			runs = append(runs, inlineRun{text: content, style: style})
+		default:
+			// Fallback to plain_text for unknown types
+			runs = append(runs, inlineRun{text: rt.PlainText, style: runStyle(rt)})
+		}
+	}
+
+	w := &inlineWriter{runs: runs, esc: esc}
+	for i := range runs {
+		w.write(i)
+	}
+	w.closeUntil(inlineStyle{})
+	w.text(w.pending)
+	return w.b.String()
+}
+
+// mergeRuns joins adjacent text runs whose markdown formatting is the
+// same.
+func mergeRuns(richText []RichText) []RichText {
+	var merged []RichText
+	for _, rt := range richText {
+		if rt.Type == "text" && rt.Text != nil {
+			if n := len(merged); n > 0 && merged[n-1].Type == "text" && merged[n-1].Text != nil && sameFormatting(merged[n-1], rt) {
+				text := *merged[n-1].Text
+				text.Content += rt.Text.Content
+				merged[n-1].Text = &text
+				continue
+			}
+		}
+		merged = append(merged, rt)
+	}
+	return merged
+}
+
+// isAutolink reports whether linked text is just its own URL, which is
+// written as <url>.
+func isAutolink(content, url string) bool {
+	if content == "" || strings.ContainsAny(content, " <>") {
+		return false
+	}
+	if content == url {
+		return strings.Contains(url, "://")
+	}
+	return url == "mailto:"+content && strings.Contains(content, "@")
+}
+
+// mentionMarkdown renders a mention. Page mentions become
+// [@Title](notion://page-id); user, date and other mentions render as their
+// plain text.
+func mentionMarkdown(rt RichText) string {
+	if rt.Mention != nil && rt.Mention.Type == "page" && rt.Mention.Page != nil {
+		plainText := rt.PlainText
+		if plainText == "" {
+			plainText = "Page"
+		}
+		return fmt.Sprintf("[@%s](notion://%s)", escapeLinkText(plainText), rt.Mention.Page.ID)
+	}
+	return rt.PlainText
+}
+
+// inlineWriter writes inline runs as markdown, keeping a stack of open
+// markers.
+type inlineWriter struct {
+	b    strings.Builder
+	runs []inlineRun
+	esc  *textEscaper
+
+	open  []inlineLayer
+	link  string // URL of the open link
+	color string // color of the open <span>
+
+	// Backtick fence of the open code span, and whether its content is
+	// padded with spaces.
+	codeFence string
+	codePad   bool
+
+	// Whitespace from the end of the previous run, written once it's
+	// known which markers stay open around it.
+	pending string
+}
+
+// write writes run i.
+func (w *inlineWriter) write(i int) {
+	r := w.runs[i]
+	if r.text == "" {
+		return
+	}
+
+	body := r.text
+	trail := ""
+	edges := " \t\n"
+	if !r.style.bold && !r.style.italic && !r.style.strikethrough && r.style.link == "" {
+		edges = "\n"
+	}
+	if !r.atom && strings.Trim(body, edges) == "" && !r.style.code {
+		// Whitespace-only run: it still ends any formatting it doesn't share
+		w.closeUntil(r.style)
+		w.pending += body
+		return
+	}
+	if !r.atom && strings.Trim(body, edges) != "" {
+		trimmed := strings.TrimLeft(body, edges)
+		w.pending += body[:len(body)-len(trimmed)]
+		body = strings.TrimRight(trimmed, edges)
+		trail = trimmed[len(body):]
+	}
+
+	w.closeUntil(r.style)
+	if r.style.code && w.isOpen(layerCode) {
+		// Each code run gets its own span, since the fence depends on the content
+		w.closeTop()
+	}
+	w.text(w.pending)
+	w.pending = ""
+	w.openMissing(i, body)
+	switch {
+	case r.atom:
+		w.raw(body)
+	case r.style.code:
+		if w.esc.table {
+			body = strings.ReplaceAll(body, "|", `\|`)
+		}
+		w.raw(body)
+	default:
+		w.text(body)
+	}
+	w.pending = trail
+}
+
+// text writes plain text, escaped.
+func (w *inlineWriter) text(s string) {
+	w.b.WriteString(w.esc.escape(s))
+}
+
+// raw writes markdown syntax or pre-rendered markdown.
+func (w *inlineWriter) raw(s string) {
+	if s != "" {
+		w.b.WriteString(s)
+		w.esc.lineStart = false
+	}
+}
+
+// closeUntil closes markers from the top of the stack until every open
+// marker is also part of style.
+func (w *inlineWriter) closeUntil(style inlineStyle) {
+	for i, l := range w.open {
+		if !style.has(l) || (l == layerLink && style.link != w.link) || (l == layerColor && style.color != w.color) {
+			for len(w.open) > i {
+				w.closeTop()
+			}
+			return
+		}
+	}
+}
+
+func (w *inlineWriter) isOpen(l inlineLayer) bool {
+	for _, o := range w.open {
+		if o == l {
+			return true
+		}
+	}
+	return false
+}
+
+// openMissing opens the markers run i needs that aren't open yet. Markers
+// that stay in effect for more of the following runs are opened first, so
+// they enclose the shorter ones. Code is always innermost.
+func (w *inlineWriter) openMissing(i int, content string) {
+	style := w.runs[i].style
+	var missing []inlineLayer
+	for l := layerLink; l < layerCount; l++ {
+		if style.has(l) && !w.isOpen(l) {
+			missing = append(missing, l)
+		}
+	}
+	sort.SliceStable(missing, func(a, b int) bool {
+		if missing[a] == layerCode || missing[b] == layerCode {
+			return missing[b] == layerCode && missing[a] != layerCode
+		}
+		return w.extent(i, missing[a]) > w.extent(i, missing[b])
+	})
+
+	for _, l := range missing {
+		switch l {
+		case layerLink:
+			w.raw("[")
+			w.link = style.link
+		case layerBold:
+			w.raw("**")
+		case layerItalic:
+			w.raw("*")
+		case layerStrikethrough:
+			w.raw("~~")
+		case layerUnderline:
+			w.raw("<u>")
+		case layerColor:
+			w.raw(colorTag(style.color))
+			w.color = style.color
+		case layerCode:
+			w.codeFence = codeSpanFence(content)
+			w.codePad = needsCodePadding(content)
+			w.raw(w.codeFence)
+			if w.codePad {
+				w.raw(" ")
+			}
+		}
+		w.open = append(w.open, l)
+	}
+}
+
+// extent returns how many runs, starting at i, keep layer l in effect.
+func (w *inlineWriter) extent(i int, l inlineLayer) int {
+	style := w.runs[i].style
+	n := 0
+	for _, r := range w.runs[i:] {
+		if !r.style.has(l) || (l == layerLink && r.style.link != style.link) || (l == layerColor && r.style.color != style.color) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// closeTop closes the innermost open marker.
+func (w *inlineWriter) closeTop() {
+	l := w.open[len(w.open)-1]
+	w.open = w.open[:len(w.open)-1]
+	switch l {
+	case layerLink:
+		w.raw("](" + linkDestination(w.link) + ")")
+		w.link = ""
+	case layerBold:
+		w.raw("**")
+	case layerItalic:
+		w.raw("*")
+	case layerStrikethrough:
+		w.raw("~~")
+	case layerUnderline:
+		w.raw("</u>")
+	case layerColor:
+		w.raw("</span>")
+		w.color = ""
+	case layerCode:
+		if w.codePad {
+			w.raw(" ")
+		}
+		w.raw(w.codeFence)
+	}
+}
+
+// codeSpanFence returns a backtick run longer than any in code.
+func codeSpanFence(code string) string {
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence
+}
+
+// needsCodePadding reports whether a code span needs a space inside each
+// fence, because a backtick at either edge would merge with the fence, or
+// because CommonMark would strip a space at both edges.
+func needsCodePadding(code string) bool {
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		return true
+	}
+	return strings.HasPrefix(code, " ") && strings.HasSuffix(code, " ") && strings.Trim(code, " ") != ""
+}
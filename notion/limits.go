@@ -0,0 +1,205 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Request limits of the Notion API.
+const (
+	maxTextLength        = 2000 // characters in one rich text run
+	maxRichTextRuns      = 100  // runs in one rich_text array
+	maxBlocksPerAppend   = 100  // top-level children in one append request
+	maxElementsPerAppend = 1000 // blocks in one append request, nested ones included
+	// maxAppendPayload stays under Notion's 500KB body limit with some
+	// room for the request envelope.
+	maxAppendPayload = 450 * 1000
+)
+
+// splitLongText makes blocks fit Notion's rich text limits. Text runs over
+// maxTextLength characters are split into several runs with the same
+// formatting, and blocks that end up with more than maxRichTextRuns runs
+// are continued in further blocks of the same type. List items, to_dos and
+// toggles are continued in child paragraphs instead, since another block
+// of their type would read as a new item.
+func splitLongText(blocks []Block) []Block {
+	var result []Block
+	for _, b := range blocks {
+		b.RichText = splitLongRuns(b.RichText)
+		b.Caption = splitLongRuns(b.Caption)
+		if b.Cells != nil {
+			cells := make([][]RichText, len(b.Cells))
+			for i, cell := range b.Cells {
+				cells[i] = splitLongRuns(cell)
+			}
+			b.Cells = cells
+		}
+		b.Children = splitLongText(b.Children)
+
+		if len(b.RichText) <= maxRichTextRuns {
+			result = append(result, b)
+			continue
+		}
+
+		// The first part keeps the block's children and other content; the
+		// rest only continue its text.
+		runs := b.RichText
+		b.RichText = runs[:maxRichTextRuns]
+		var rest []Block
+		for runs = runs[maxRichTextRuns:]; len(runs) > 0; {
+			n := min(len(runs), maxRichTextRuns)
+			if continuedInChildren[b.Type] {
+				rest = append(rest, Block{Type: "paragraph", RichText: runs[:n], Color: b.Color})
+			} else {
+				rest = append(rest, Block{Type: b.Type, RichText: runs[:n], Language: b.Language, Checked: b.Checked, Color: b.Color, Icon: b.Icon, IsToggleable: b.IsToggleable})
+			}
+			runs = runs[n:]
+		}
+		if continuedInChildren[b.Type] {
+			b.Children = append(rest, b.Children...)
+			result = append(result, b)
+		} else {
+			result = append(result, b)
+			result = append(result, rest...)
+		}
+	}
+	return result
+}
+
+// continuedInChildren are the block types whose text splitLongText
+// continues in child paragraphs.
+var continuedInChildren = map[string]bool{
+	"bulleted_list_item": true,
+	"numbered_list_item": true,
+	"to_do":              true,
+	"toggle":             true,
+}
+
+// checkTextRuns reports text in blocks, or their descendants, with more
+// runs than one rich_text array can hold. splitLongText can only continue
+// block text; captions and table cells have to fit.
+func checkTextRuns(blocks []Block) error {
+	for i, b := range blocks {
+		if len(b.Caption) > maxRichTextRuns {
+			return fmt.Errorf("block %d (%s) has a caption of %d text runs, over the %d run limit", i, b.Type, len(b.Caption), maxRichTextRuns)
+		}
+		for j, cell := range b.Cells {
+			if len(cell) > maxRichTextRuns {
+				return fmt.Errorf("block %d (%s) has %d text runs in cell %d, over the %d run limit", i, b.Type, len(cell), j+1, maxRichTextRuns)
+			}
+		}
+		if err := checkTextRuns(b.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitLongRuns splits text runs longer than maxTextLength.
+func splitLongRuns(richText []RichText) []RichText {
+	var result []RichText
+	for _, rt := range richText {
+		if rt.Type != "text" || rt.Text == nil || textLength(rt.Text.Content) <= maxTextLength {
+			result = append(result, rt)
+			continue
+		}
+		for _, part := range splitText(rt.Text.Content, maxTextLength) {
+			piece := rt
+			text := *rt.Text
+			text.Content = part
+			piece.Text = &text
+			result = append(result, piece)
+		}
+	}
+	return result
+}
+
+// splitText splits s into parts of at most limit characters, preferring to
+// break after a newline or space in the second half of each part.
+func splitText(s string, limit int) []string {
+	var parts []string
+	for textLength(s) > limit {
+		// Byte offset of the limit-th character
+		cut, n := 0, 0
+		for cut < len(s) {
+			r, size := utf8.DecodeRuneInString(s[cut:])
+			units := max(utf16.RuneLen(r), 1)
+			if n+units > limit {
+				break
+			}
+			n += units
+			cut += size
+		}
+
+		if i := strings.LastIndexByte(s[:cut], '\n'); i >= cut/2 {
+			cut = i + 1
+		} else if i := strings.LastIndexByte(s[:cut], ' '); i >= cut/2 {
+			cut = i + 1
+		}
+		parts = append(parts, s[:cut])
+		s = s[cut:]
+	}
+	return append(parts, s)
+}
+
+// textLength returns the length of s as Notion counts it, in UTF-16 code
+// units.
+func textLength(s string) int {
+	n := 0
+	for _, r := range s {
+		n += max(utf16.RuneLen(r), 1)
+	}
+	return n
+}
+
+// planAppendBatches groups blocks into append requests that stay within
+// Notion's limits on the number of children, the total number of nested
+// blocks, and the request size. It fails if a single block is too large to
+// send, so callers can check before changing anything.
+func planAppendBatches(blocks []Block) ([][]Block, error) {
+	var batches [][]Block
+	var batch []Block
+	elements, size := 0, 0
+
+	for i, b := range blocks {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal block %d: %w", i, err)
+		}
+		blockSize := len(data) + 1
+		blockElements := countBlocks(b)
+		if blockSize > maxAppendPayload {
+			return nil, fmt.Errorf("block %d (%s) is %d bytes, over the %d byte request limit", i, b.Type, blockSize, maxAppendPayload)
+		}
+		if blockElements > maxElementsPerAppend {
+			return nil, fmt.Errorf("block %d (%s) has %d nested blocks, over the %d block request limit", i, b.Type, blockElements, maxElementsPerAppend)
+		}
+
+		if len(batch) > 0 && (len(batch) == maxBlocksPerAppend ||
+			elements+blockElements > maxElementsPerAppend ||
+			size+blockSize > maxAppendPayload) {
+			batches = append(batches, batch)
+			batch, elements, size = nil, 0, 0
+		}
+		batch = append(batch, b)
+		elements += blockElements
+		size += blockSize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// countBlocks returns the number of blocks in b, including b and all of its
+// descendants.
+func countBlocks(b Block) int {
+	n := 1
+	for _, child := range b.Children {
+		n += countBlocks(child)
+	}
+	return n
+}
@@ -0,0 +1,559 @@
+package notion
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// LocalSearchResult is one matching section of a pulled markdown file.
+type LocalSearchResult struct {
+	FilePath    string `json:"file_path"`
+	NotionID    string `json:"notion_id"`
+	HeadingPath string `json:"heading_path,omitempty"` // e.g. "Design > API"
+	Snippet     string `json:"snippet"`
+	Score       int    `json:"score"`
+}
+
+// LocalIndex is an inverted index over the pulled markdown files in a scope.
+// Files are indexed by section (the text under each heading) so results can
+// point at the relevant part of a page. The index is refreshed incrementally:
+// only files whose modification time changed are re-read.
+type LocalIndex struct {
+	mu        sync.Mutex
+	scope     string
+	recursive bool
+	docs      map[string]*indexedDoc               // path -> document
+	postings  map[string]map[*indexedSection][]int // term -> section -> token positions
+}
+
+type indexedDoc struct {
+	path     string
+	notionID string
+	modTime  time.Time
+	size     int64
+	sections []*indexedSection
+}
+
+type indexedSection struct {
+	doc      *indexedDoc
+	headings []string
+	text     string
+	offsets  []int // Byte offset in text of each token
+}
+
+var (
+	localIndexesMu sync.Mutex
+	localIndexes   = make(map[string]*LocalIndex) // scope -> index, kept for the life of the server
+)
+
+// LocalSearch searches the pulled markdown files in scope without any API calls.
+//
+// Queries are words and "quoted phrases", combined with AND (the default
+// between terms), OR, NOT (or a leading -) and parentheses. Matching is
+// case-insensitive and per section.
+func LocalSearch(scope string, recursive bool, query string, limit int) ([]LocalSearchResult, error) {
+	absScope, err := filepath.Abs(scope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scope: %w", err)
+	}
+
+	key := fmt.Sprintf("%s|%v", absScope, recursive)
+	localIndexesMu.Lock()
+	idx, ok := localIndexes[key]
+	if !ok {
+		idx = &LocalIndex{
+			scope:     absScope,
+			recursive: recursive,
+			docs:      make(map[string]*indexedDoc),
+			postings:  make(map[string]map[*indexedSection][]int),
+		}
+		localIndexes[key] = idx
+	}
+	localIndexesMu.Unlock()
+
+	if err := idx.Refresh(); err != nil {
+		return nil, err
+	}
+	return idx.Search(query, limit)
+}
+
+// Refresh re-indexes new and modified files and drops deleted ones.
+// Like ScanForNotionIDs, only .md files with a notion_id are indexed.
+func (idx *LocalIndex) Refresh() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var updated, removed int
+	err := walkMarkdownFiles(idx.scope, idx.recursive, func(path string, info os.FileInfo) {
+		seen[path] = true
+		if doc, ok := idx.docs[path]; ok && doc.modTime.Equal(info.ModTime()) && doc.size == info.Size() {
+			return
+		}
+
+		idx.removeDoc(path)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		pageID, markdown := parseFrontmatter(string(content))
+		if pageID == "" {
+			return
+		}
+		idx.addDoc(&indexedDoc{
+			path:     path,
+			notionID: strings.ReplaceAll(pageID, "-", ""),
+			modTime:  info.ModTime(),
+			size:     info.Size(),
+		}, markdown)
+		updated++
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan scope: %w", err)
+	}
+
+	for path := range idx.docs {
+		if !seen[path] {
+			idx.removeDoc(path)
+			removed++
+		}
+	}
+
+	debugLog("LocalIndex.Refresh: %s: %d files indexed, %d updated, %d removed", idx.scope, len(idx.docs), updated, removed)
+	return nil
+}
+
+// addDoc splits markdown into sections by heading and indexes each one.
+func (idx *LocalIndex) addDoc(doc *indexedDoc, markdown string) {
+	var headings []string
+	var body strings.Builder
+	inFence := false
+
+	flush := func() {
+		text := strings.TrimSpace(body.String())
+		body.Reset()
+		if text == "" && len(headings) == 0 {
+			return
+		}
+		section := &indexedSection{
+			doc:      doc,
+			headings: append([]string{}, headings...),
+			text:     text,
+		}
+		// Headings are searchable as part of their section.
+		tokens, offsets := tokenizeForIndex(strings.Join(headings, " ") + "\n" + text)
+		headingLen := len(strings.Join(headings, " ")) + 1
+		for i, tok := range tokens {
+			if idx.postings[tok] == nil {
+				idx.postings[tok] = make(map[*indexedSection][]int)
+			}
+			idx.postings[tok][section] = append(idx.postings[tok][section], i)
+			// Offsets are relative to the section text; heading tokens point at its start.
+			off := offsets[i] - headingLen
+			if off < 0 {
+				off = 0
+			}
+			section.offsets = append(section.offsets, off)
+		}
+		doc.sections = append(doc.sections, section)
+	}
+
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(line, "#") {
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			if level <= 6 && len(line) > level && line[level] == ' ' {
+				flush()
+				if level > len(headings)+1 {
+					level = len(headings) + 1
+				}
+				headings = append(headings[:level-1], strings.TrimSpace(line[level:]))
+				continue
+			}
+		}
+		body.WriteString(line + "\n")
+	}
+	flush()
+
+	idx.docs[doc.path] = doc
+}
+
+// removeDoc drops a document and its postings from the index.
+func (idx *LocalIndex) removeDoc(path string) {
+	doc, ok := idx.docs[path]
+	if !ok {
+		return
+	}
+	for _, section := range doc.sections {
+		tokens, _ := tokenizeForIndex(strings.Join(section.headings, " ") + "\n" + section.text)
+		for _, tok := range tokens {
+			if m := idx.postings[tok]; m != nil {
+				delete(m, section)
+				if len(m) == 0 {
+					delete(idx.postings, tok)
+				}
+			}
+		}
+	}
+	delete(idx.docs, path)
+}
+
+// Search evaluates a query against the index.
+func (idx *LocalIndex) Search(query string, limit int) ([]LocalSearchResult, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	node, err := parseLocalQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := node.eval(idx)
+	var terms []string
+	node.positiveTerms(&terms)
+
+	var results []LocalSearchResult
+	for section := range matches {
+		score := 0
+		firstOffset := -1
+		for _, term := range terms {
+			positions := idx.postings[term][section]
+			score += len(positions)
+			if len(positions) > 0 {
+				off := section.offsets[positions[0]]
+				if firstOffset == -1 || off < firstOffset {
+					firstOffset = off
+				}
+			}
+		}
+		results = append(results, LocalSearchResult{
+			FilePath:    section.doc.path,
+			NotionID:    section.doc.notionID,
+			HeadingPath: strings.Join(section.headings, " > "),
+			Snippet:     makeSnippet(section.text, firstOffset),
+			Score:       score,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].FilePath != results[j].FilePath {
+			return results[i].FilePath < results[j].FilePath
+		}
+		return results[i].HeadingPath < results[j].HeadingPath
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// makeSnippet returns about 160 characters of text around offset, on one line.
+func makeSnippet(text string, offset int) string {
+	const radius = 80
+	if offset < 0 {
+		offset = 0
+	}
+	start := offset - radius
+	if start < 0 {
+		start = 0
+	}
+	end := offset + radius
+	if end > len(text) {
+		end = len(text)
+	}
+	// Avoid cutting UTF-8 sequences in half.
+	for start > 0 && start < len(text) && text[start]&0xC0 == 0x80 {
+		start--
+	}
+	for end < len(text) && text[end]&0xC0 == 0x80 {
+		end++
+	}
+
+	snippet := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// tokenizeForIndex splits text into lowercase words, returning each word
+// and its byte offset.
+func tokenizeForIndex(text string) ([]string, []int) {
+	var tokens []string
+	var offsets []int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start == -1 {
+			start = i
+		} else if !isWord && start != -1 {
+			tokens = append(tokens, strings.ToLower(text[start:i]))
+			offsets = append(offsets, start)
+			start = -1
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, strings.ToLower(text[start:]))
+		offsets = append(offsets, start)
+	}
+	return tokens, offsets
+}
+
+// Query parsing
+
+type localQueryNode interface {
+	eval(idx *LocalIndex) map[*indexedSection]bool
+	positiveTerms(terms *[]string)
+}
+
+type localTermNode struct {
+	words []string // More than one word for phrases
+}
+
+type localAndNode struct{ children []localQueryNode }
+type localOrNode struct{ children []localQueryNode }
+type localNotNode struct{ inner localQueryNode }
+
+func (n *localTermNode) eval(idx *LocalIndex) map[*indexedSection]bool {
+	result := make(map[*indexedSection]bool)
+	if len(n.words) == 0 {
+		return result
+	}
+	for section, positions := range idx.postings[n.words[0]] {
+		if len(n.words) == 1 {
+			result[section] = true
+			continue
+		}
+		// Phrase: every following word must appear at the next position.
+		for _, pos := range positions {
+			if phraseAt(idx, section, n.words, pos) {
+				result[section] = true
+				break
+			}
+		}
+	}
+	return result
+}
+
+func phraseAt(idx *LocalIndex, section *indexedSection, words []string, pos int) bool {
+	for i, w := range words[1:] {
+		found := false
+		for _, p := range idx.postings[w][section] {
+			if p == pos+i+1 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *localTermNode) positiveTerms(terms *[]string) {
+	*terms = append(*terms, n.words...)
+}
+
+func (n *localAndNode) eval(idx *LocalIndex) map[*indexedSection]bool {
+	var result map[*indexedSection]bool
+	for _, child := range n.children {
+		set := child.eval(idx)
+		if result == nil {
+			result = set
+			continue
+		}
+		for section := range result {
+			if !set[section] {
+				delete(result, section)
+			}
+		}
+	}
+	return result
+}
+
+func (n *localAndNode) positiveTerms(terms *[]string) {
+	for _, child := range n.children {
+		child.positiveTerms(terms)
+	}
+}
+
+func (n *localOrNode) eval(idx *LocalIndex) map[*indexedSection]bool {
+	result := make(map[*indexedSection]bool)
+	for _, child := range n.children {
+		for section := range child.eval(idx) {
+			result[section] = true
+		}
+	}
+	return result
+}
+
+func (n *localOrNode) positiveTerms(terms *[]string) {
+	for _, child := range n.children {
+		child.positiveTerms(terms)
+	}
+}
+
+func (n *localNotNode) eval(idx *LocalIndex) map[*indexedSection]bool {
+	excluded := n.inner.eval(idx)
+	result := make(map[*indexedSection]bool)
+	for _, doc := range idx.docs {
+		for _, section := range doc.sections {
+			if !excluded[section] {
+				result[section] = true
+			}
+		}
+	}
+	return result
+}
+
+func (n *localNotNode) positiveTerms(terms *[]string) {}
+
+type localQueryParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseLocalQuery parses a search query into an expression tree.
+func parseLocalQuery(query string) (localQueryNode, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '-' && i+1 < len(query) && query[i+1] != ' ':
+			tokens = append(tokens, "NOT")
+			i++
+		case c == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated phrase at position %d", i+1)
+			}
+			tokens = append(tokens, query[i:i+end+2])
+			i += end + 2
+		default:
+			j := i
+			for j < len(query) && !strings.ContainsRune(" \t\n()\"", rune(query[j])) {
+				j++
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("query is empty")
+	}
+
+	p := &localQueryParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in query", p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+func (p *localQueryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *localQueryParser) parseOr() (localQueryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []localQueryNode{left}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &localOrNode{children: children}, nil
+}
+
+func (p *localQueryParser) parseAnd() (localQueryNode, error) {
+	var children []localQueryNode
+	for {
+		tok := p.peek()
+		if tok == "" || tok == ")" || tok == "OR" {
+			break
+		}
+		if tok == "AND" {
+			p.pos++
+			continue
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		return nil, fmt.Errorf("expected a search term")
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &localAndNode{children: children}, nil
+}
+
+func (p *localQueryParser) parseUnary() (localQueryNode, error) {
+	tok := p.peek()
+	p.pos++
+	switch {
+	case tok == "NOT":
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &localNotNode{inner: inner}, nil
+	case tok == "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("expected ')' in query")
+		}
+		p.pos++
+		return inner, nil
+	case strings.HasPrefix(tok, "\""):
+		words, _ := tokenizeForIndex(strings.Trim(tok, "\""))
+		return &localTermNode{words: words}, nil
+	}
+	// Punctuated words such as e-mail tokenize to several words and
+	// behave like phrases.
+	words, _ := tokenizeForIndex(tok)
+	return &localTermNode{words: words}, nil
+}
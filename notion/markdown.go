@@ -2,285 +2,331 @@ package notion
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
 // MarkdownToBlocks converts markdown text to Notion block structures.
-func MarkdownToBlocks(markdown string) []map[string]any {
-	lines := splitLines(markdown)
-	blocks, _ := parseBlocksWithIndent(lines, 0, 0)
-	return blocks
+//
+// Markdown is parsed as CommonMark with GFM tables, task lists and
+// strikethrough, and the syntax tree is mapped onto Notion blocks. Text
+// longer than Notion accepts in one rich text run is split. Dollar signs
+// delimit $$ equation blocks and $ inline equations.
+func MarkdownToBlocks(markdown string) []Block {
+	source := []byte(markdown)
+	doc := markdownParser.Parse(text.NewReader(source))
+	return splitLongText(nodesToBlocks(doc, source))
 }
 
-// parseBlocksWithIndent parses lines starting at startIdx with the given minimum indent level.
-// Returns the parsed blocks and the next line index to process.
-func parseBlocksWithIndent(lines []string, startIdx int, minIndent int) ([]map[string]any, int) {
-	var blocks []map[string]any
-
-	for i := startIdx; i < len(lines); i++ {
-		line := lines[i]
-
-		// Skip empty lines
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		// Check indent level
-		indent := getIndentLevel(line)
-		if indent < minIndent {
-			// Back to parent level, return
-			return blocks, i
-		}
-
-		// Remove the minimum indent from the line for processing
-		if indent >= minIndent && minIndent > 0 {
-			if len(line) >= minIndent {
-				line = line[minIndent:]
-			}
-		}
+var markdownParser = parser.NewParser(
+	parser.WithBlockParsers(append(parser.DefaultBlockParsers(),
+		util.Prioritized(&mathBlockParser{}, 750),
+	)...),
+	parser.WithInlineParsers(append(parser.DefaultInlineParsers(),
+		util.Prioritized(extension.NewStrikethroughParser(), 500),
+		util.Prioritized(extension.NewTaskCheckBoxParser(), 0),
+		util.Prioritized(&mathInlineParser{}, 600),
+	)...),
+	parser.WithParagraphTransformers(append(parser.DefaultParagraphTransformers(),
+		util.Prioritized(extension.NewTableParagraphTransformer(), 200),
+	)...),
+	parser.WithASTTransformers(
+		util.Prioritized(extension.NewTableASTTransformer(), 0),
+	),
+)
 
-		// Skip child_page comment markers - these are placeholders, actual child pages are restored separately
-		if strings.HasPrefix(line, "<!-- child_page:") && strings.HasSuffix(line, "-->") {
-			continue
-		}
+// nodesToBlocks converts the children of an AST node to Notion blocks.
+func nodesToBlocks(parent ast.Node, source []byte) []Block {
+	return siblingsToBlocks(parent.FirstChild(), source)
+}
 
-		// Heading 1
-		if len(line) > 2 && line[0] == '#' && line[1] == ' ' {
-			blocks = append(blocks, map[string]any{
-				"object": "block",
-				"type":   "heading_1",
-				"heading_1": map[string]any{
-					"rich_text": parseInlineMarkdown(line[2:]),
-				},
-			})
-			continue
-		}
+// siblingsToBlocks converts first and the siblings after it to Notion
+// blocks. An indented code block right after a block that can hold
+// children is parsed as markdown and becomes that block's children.
+func siblingsToBlocks(first ast.Node, source []byte) []Block {
+	var nodes []ast.Node
+	for n := first; n != nil; n = n.NextSibling() {
+		nodes = append(nodes, n)
+	}
+	return nodeListToBlocks(nodes, source)
+}
 
-		// Heading 2
-		if len(line) > 3 && line[0:3] == "## " {
-			blocks = append(blocks, map[string]any{
-				"object": "block",
-				"type":   "heading_2",
-				"heading_2": map[string]any{
-					"rich_text": parseInlineMarkdown(line[3:]),
-				},
-			})
+// nodeListToBlocks converts a run of sibling nodes. It handles constructs
+// that span several nodes, such as a children section, a <details> toggle,
+// a column list or a synced block whose content sits between two HTML
+// blocks.
+func nodeListToBlocks(nodes []ast.Node, source []byte) []Block {
+	var blocks []Block
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		if childrenMarkerAt(nodes, i, source) == childrenOpen {
+			end := childrenEnd(nodes, i+1, source)
+			children := nodeListToBlocks(nodes[i+1:end], source)
+			if len(blocks) > 0 && holdsChildrenSection(blocks[len(blocks)-1]) {
+				last := &blocks[len(blocks)-1]
+				last.Children = append(last.Children, children...)
+			} else {
+				// Nothing to nest under; keep the content in place
+				blocks = append(blocks, children...)
+			}
+			i = end
 			continue
 		}
-
-		// Heading 3
-		if len(line) > 4 && line[0:4] == "### " {
-			blocks = append(blocks, map[string]any{
-				"object": "block",
-				"type":   "heading_3",
-				"heading_3": map[string]any{
-					"rich_text": parseInlineMarkdown(line[4:]),
-				},
-			})
+		if synced, ok := syncedBlockAt(nodes, i, source); ok {
+			end := syncedEnd(nodes, i+1, source)
+			synced.Children = nodeListToBlocks(nodes[i+1:end], source)
+			blocks = append(blocks, synced)
+			i = end
 			continue
 		}
-
-		// Divider
-		if line == "---" {
-			blocks = append(blocks, map[string]any{
-				"object":  "block",
-				"type":    "divider",
-				"divider": map[string]any{},
-			})
+		if columnsMarkerAt(nodes, i, source) == columnsOpen {
+			end := columnsEnd(nodes, i+1, source)
+			blocks = append(blocks, columnsToBlocks(nodes[i+1:end], source)...)
+			// Continue after the closing marker
+			i = end
 			continue
 		}
-
-		// Code block (fenced with ```)
-		if len(line) >= 3 && line[0:3] == "```" {
-			lang := strings.TrimSpace(line[3:])
-			var codeLines []string
-			// Collect lines until closing ```
-			for i+1 < len(lines) {
-				i++
-				if len(lines[i]) >= 3 && lines[i][0:3] == "```" {
-					break
+		if html, ok := n.(*ast.HTMLBlock); ok {
+			if toggle, closed, ok := detailsToBlock(htmlBlockText(html, source)); ok {
+				if !closed {
+					end := detailsEnd(nodes, i+1, source)
+					toggle.Children = append(toggle.Children, nodeListToBlocks(nodes[i+1:end], source)...)
+					// Continue after the closing </details>
+					i = end
 				}
-				codeLines = append(codeLines, lines[i])
-			}
-			codeContent := strings.Join(codeLines, "\n")
-			// Map common language aliases to Notion's expected values
-			notionLang := mapLanguageToNotion(lang)
-			blocks = append(blocks, map[string]any{
-				"object": "block",
-				"type":   "code",
-				"code": map[string]any{
-					"rich_text": []map[string]any{
-						{"type": "text", "text": map[string]string{"content": codeContent}},
-					},
-					"language": notionLang,
-				},
-			})
-			continue
-		}
-
-		// Checkbox (to_do)
-		if len(line) > 5 && (line[0:5] == "- [ ]" || line[0:5] == "- [x]") {
-			checked := line[3] == 'x'
-			text := ""
-			if len(line) > 6 {
-				text = line[6:]
+				blocks = append(blocks, toggle)
+				continue
 			}
-			blocks = append(blocks, map[string]any{
-				"object": "block",
-				"type":   "to_do",
-				"to_do": map[string]any{
-					"rich_text": parseInlineMarkdown(text),
-					"checked":   checked,
-				},
-			})
-			continue
 		}
+		blocks = append(blocks, nodeToBlocks(n, source)...)
+	}
+	return blocks
+}
 
-		// Bullet list
-		if len(line) > 2 && line[0:2] == "- " {
-			block := map[string]any{
-				"object": "block",
-				"type":   "bulleted_list_item",
-				"bulleted_list_item": map[string]any{
-					"rich_text": parseInlineMarkdown(line[2:]),
-				},
-			}
-			// Check for indented children - children go at block level for Notion API
-			childIndent := indent + minIndent + 2 // At least 2 more spaces for children
-			if i+1 < len(lines) {
-				children, nextIdx := parseBlocksWithIndent(lines, i+1, childIndent)
-				if len(children) > 0 {
-					block["children"] = children
-				}
-				i = nextIdx - 1 // -1 because loop will i++
-			}
-			blocks = append(blocks, block)
-			continue
-		}
+// parseNested parses a separate piece of markdown, such as the content of
+// an HTML block, into blocks.
+func parseNested(markdown string) []Block {
+	source := []byte(markdown)
+	return nodesToBlocks(markdownParser.Parse(text.NewReader(source)), source)
+}
 
-		// Numbered list
-		if len(line) > 3 && line[0] >= '0' && line[0] <= '9' {
-			dotIdx := -1
-			for j := 1; j < len(line) && j < 4; j++ {
-				if line[j] == '.' && j+1 < len(line) && line[j+1] == ' ' {
-					dotIdx = j
-					break
-				}
-				if line[j] < '0' || line[j] > '9' {
-					break
-				}
-			}
-			if dotIdx > 0 {
-				block := map[string]any{
-					"object": "block",
-					"type":   "numbered_list_item",
-					"numbered_list_item": map[string]any{
-						"rich_text": parseInlineMarkdown(line[dotIdx+2:]),
-					},
-				}
-				// Check for indented children - children go at block level for Notion API
-				childIndent := indent + minIndent + 3 // At least 3 more spaces for children (to align with text after "1. ")
-				if i+1 < len(lines) {
-					children, nextIdx := parseBlocksWithIndent(lines, i+1, childIndent)
-					if len(children) > 0 {
-						block["children"] = children
-					}
-					i = nextIdx - 1 // -1 because loop will i++
-				}
-				blocks = append(blocks, block)
-				continue
-			}
+// nodeToBlocks converts a single AST node. Most nodes map to one block;
+// lists map to one block per item.
+func nodeToBlocks(n ast.Node, source []byte) []Block {
+	switch node := n.(type) {
+	case *ast.Heading:
+		blockType := "heading_3"
+		if node.Level < 3 {
+			blockType = fmt.Sprintf("heading_%d", node.Level)
+		}
+		color := cutBlockColor(node, source)
+		block := newTextBlock(blockType, inlineToRichText(node, source))
+		block.Color = color
+		return []Block{block}
+
+	case *ast.Paragraph, *ast.TextBlock:
+		if placeholder, ok := paragraphToPlaceholder(node, source); ok {
+			return []Block{placeholder}
+		}
+		if media, ok := paragraphToMedia(node, source); ok {
+			return []Block{media}
+		}
+		color := cutBlockColor(node, source)
+		block := newTextBlock("paragraph", inlineToRichText(node, source))
+		block.Color = color
+		return []Block{block}
+
+	case *ast.ThematicBreak:
+		return []Block{{Type: "divider"}}
+
+	case *mathBlock:
+		return []Block{{Type: "equation", Expression: codeText(node, source)}}
+
+	case *ast.FencedCodeBlock:
+		return []Block{{
+			Type:     "code",
+			RichText: plainRichText(codeText(node, source)),
+			Language: mapLanguageToNotion(string(node.Language(source))),
+		}}
+
+	case *ast.CodeBlock:
+		return []Block{{
+			Type:     "code",
+			RichText: plainRichText(codeText(node, source)),
+			Language: mapLanguageToNotion(""),
+		}}
+
+	case *ast.Blockquote:
+		return blockquoteToBlocks(node, source)
+
+	case *ast.List:
+		var blocks []Block
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			blocks = append(blocks, listItemToBlock(node, item, source))
 		}
+		return blocks
 
-		// Quote
-		if len(line) > 2 && line[0:2] == "> " {
-			blocks = append(blocks, map[string]any{
-				"object": "block",
-				"type":   "quote",
-				"quote": map[string]any{
-					"rich_text": parseInlineMarkdown(line[2:]),
-				},
-			})
-			continue
+	case *ast.HTMLBlock:
+		raw := strings.TrimSpace(htmlBlockText(node, source))
+		// Skip child_page comment markers - these are placeholders, actual child pages are restored separately
+		if strings.HasPrefix(raw, "<!-- child_page:") && strings.HasSuffix(raw, "-->") {
+			return nil
 		}
-
-		// Table
-		if len(line) > 0 && line[0] == '|' {
-			tableRows := []string{line}
-			for i+1 < len(lines) && len(lines[i+1]) > 0 && lines[i+1][0] == '|' {
-				i++
-				tableRows = append(tableRows, lines[i])
-			}
-			tableBlock := parseMarkdownTable(tableRows)
-			if tableBlock != nil {
-				blocks = append(blocks, tableBlock)
-			}
-			continue
+		if database, ok := childDatabaseToBlock(raw); ok {
+			return []Block{database}
 		}
+		// Notion has no raw HTML; keep it as text so nothing is lost
+		return []Block{newTextBlock("paragraph", plainRichText(raw))}
 
-		// Default: paragraph
-		blocks = append(blocks, map[string]any{
-			"object": "block",
-			"type":   "paragraph",
-			"paragraph": map[string]any{
-				"rich_text": parseInlineMarkdown(line),
-			},
-		})
+	case *extast.Table:
+		return []Block{tableToBlock(node, source)}
 	}
 
-	return blocks, len(lines)
+	// Containers we don't map directly: keep their content
+	return nodesToBlocks(n, source)
 }
 
-// getIndentLevel returns the number of leading spaces/tabs in a line.
-// Tabs are counted as 4 spaces.
-func getIndentLevel(line string) int {
-	indent := 0
-	for _, c := range line {
-		if c == ' ' {
-			indent++
-		} else if c == '\t' {
-			indent += 4
+// listItemToBlock converts a list item. Items starting with a GFM task box
+// ("[ ]" or "[x]") become to_dos. Everything after the item's first
+// paragraph becomes children.
+func listItemToBlock(list *ast.List, item ast.Node, source []byte) Block {
+	var block Block
+	rest := item.FirstChild()
+	if first := rest; first != nil && (first.Kind() == ast.KindParagraph || first.Kind() == ast.KindTextBlock) {
+		block.Color = cutBlockColor(first, source)
+		block.RichText = inlineToRichText(first, source)
+		if box, ok := first.FirstChild().(*extast.TaskCheckBox); ok {
+			block.Type = "to_do"
+			block.Checked = box.IsChecked
+			block.RichText = trimLeadingSpace(block.RichText)
+		}
+		rest = first.NextSibling()
+	}
+
+	if block.Type == "" {
+		if list.IsOrdered() {
+			block.Type = "numbered_list_item"
 		} else {
-			break
+			block.Type = "bulleted_list_item"
 		}
 	}
-	return indent
+
+	block.Children = siblingsToBlocks(rest, source)
+	return block
 }
 
-// extractChildBlocksFromBlock extracts children blocks from a block.
-// Checks both block level (for local creation) and content level (from API fetch).
-// Handles both []any (from API) and []map[string]any (from local creation).
-func extractChildBlocksFromBlock(block map[string]any, blockType string) []map[string]any {
-	// First check block level (for locally created blocks and Notion API write format)
-	if children := extractChildrenFromMap(block); len(children) > 0 {
-		return children
-	}
-	// Then check content level (for blocks fetched from Notion API)
-	if content, ok := block[blockType].(map[string]any); ok {
-		return extractChildrenFromMap(content)
+// trimLeadingSpace removes the space that separates a task box from its text.
+func trimLeadingSpace(rt []RichText) []RichText {
+	if len(rt) > 0 && rt[0].Type == "text" && rt[0].Text != nil {
+		text := *rt[0].Text
+		text.Content = strings.TrimLeft(text.Content, " \t")
+		if text.Content == "" {
+			return rt[1:]
+		}
+		rt[0].Text = &text
 	}
-	return nil
+	return rt
 }
 
-// extractChildrenFromMap extracts children array from a map, handling type variations.
-func extractChildrenFromMap(m map[string]any) []map[string]any {
-	if children, ok := m["children"].([]any); ok {
-		var blocks []map[string]any
-		for _, child := range children {
-			if block, ok := child.(map[string]any); ok {
-				blocks = append(blocks, block)
-			}
+// blockquoteToBlocks converts a blockquote to a quote block, or to a
+// callout if it starts with an alert marker such as "[!NOTE]". The first
+// paragraph is the text and anything after it becomes children.
+//
+// Comments written by formatComment are blockquotes too. They are split
+// off into quote blocks of their own, even when they directly follow other
+// quoted lines, so they never merge into the quote before them.
+func blockquoteToBlocks(node *ast.Blockquote, source []byte) []Block {
+	block := newTextBlock("quote", nil)
+	var comments []Block
+	rest := node.FirstChild()
+	if rest != nil && rest.Kind() == ast.KindParagraph {
+		lines := rest.Lines()
+		first := lines.At(0)
+		callout, isCallout := parseCalloutMarker(string(first.Value(source)))
+		if !isCallout {
+			block.Color = cutBlockColor(rest, source)
+		}
+		block.RichText = inlineToRichText(rest, source)
+		// A comment starts at a line that looks like one and runs until the
+		// next
+		for i := lines.Len() - 1; i > 0; i-- {
+			if line := lines.At(i); isCommentLine(string(line.Value(source))) {
+				head, tail := cutRichTextLines(block.RichText, i)
+				comments = append([]Block{newTextBlock("quote", tail)}, comments...)
+				block.RichText = head
+			}
+		}
+
+		if isCallout {
+			_, callout.RichText = cutRichTextLines(block.RichText, 1)
+			block = callout
+		}
+		rest = rest.NextSibling()
+	}
+	block.Children = siblingsToBlocks(rest, source)
+	return append([]Block{block}, comments...)
+}
+
+func tableToBlock(node *extast.Table, source []byte) Block {
+	var rows []Block
+	width := 0
+	for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells [][]RichText
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, inlineToRichText(cell, source))
 		}
-		return blocks
+		if len(rows) == 0 {
+			width = len(cells)
+		}
+		for len(cells) < width {
+			cells = append(cells, nil)
+		}
+		rows = append(rows, Block{Type: "table_row", Cells: cells[:width]})
+	}
+
+	return Block{
+		Type:            "table",
+		TableWidth:      width,
+		HasColumnHeader: true,
+		HasRowHeader:    false,
+		Children:        rows,
+	}
+}
+
+// codeText returns the verbatim content of a code block.
+func codeText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		b.Write(seg.Value(source))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// htmlBlockText returns the raw source of an HTML block.
+func htmlBlockText(n *ast.HTMLBlock, source []byte) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		b.Write(seg.Value(source))
 	}
-	if children, ok := m["children"].([]map[string]any); ok {
-		return children
+	if n.HasClosure() {
+		b.Write(n.ClosureLine.Value(source))
 	}
-	return nil
+	return b.String()
 }
 
 // blocksToMarkdownIndented converts blocks to markdown with each line indented.
-func blocksToMarkdownIndented(blocks []map[string]any, indent string, trailingChildPages map[string]bool) string {
+func blocksToMarkdownIndented(blocks []Block, indent string, trailingChildPages map[string]bool) string {
 	md := BlocksToMarkdownWithChildPages(blocks, trailingChildPages)
 	if md == "" {
 		return ""
@@ -301,7 +347,7 @@ func blocksToMarkdownIndented(blocks []map[string]any, indent string, trailingCh
 
 // BlocksToMarkdown converts Notion API block results to markdown.
 // This is the legacy function - use BlocksToMarkdownWithChildPages for proper child page handling.
-func BlocksToMarkdown(blocks []map[string]any) string {
+func BlocksToMarkdown(blocks []Block) string {
 	return BlocksToMarkdownWithChildPages(blocks, nil)
 }
 
@@ -309,13 +355,13 @@ func BlocksToMarkdown(blocks []map[string]any) string {
 // Child pages that are not trailing become mentions [@Title](notion://ID).
 // Trailing child pages (those after the last real content) are skipped entirely
 // since they'll be restored at the bottom anyway.
-func BlocksToMarkdownWithChildPages(blocks []map[string]any, trailingChildPages map[string]bool) string {
+func BlocksToMarkdownWithChildPages(blocks []Block, trailingChildPages map[string]bool) string {
 	var result strings.Builder
 	listNum := 1
 	lastType := ""
 
 	for _, b := range blocks {
-		blockType, _ := b["type"].(string)
+		blockType := b.Type
 		if blockType == "" {
 			continue
 		}
@@ -324,60 +370,101 @@ func BlocksToMarkdownWithChildPages(blocks []map[string]any, trailingChildPages
 			listNum = 1
 		}
 
-		text := extractBlockText(b, blockType)
+		// End the list with a blank line, or the next block would continue
+		// the last item
+		if isListItemType(lastType) && !isListItemType(blockType) {
+			result.WriteString("\n")
+		}
+
+		text := richTextToMarkdown(b.RichText)
+		if colorBlockTypes[blockType] {
+			text = blockTextWithColor(text, b.Color)
+		}
 
 		switch blockType {
 		case "heading_1":
-			result.WriteString("# " + text + "\n\n")
+			if b.IsToggleable {
+				result.WriteString(detailsMarkdown(headingSummary(1, text), b.Children, trailingChildPages))
+			} else {
+				result.WriteString("# " + escapeClosingHashes(text) + "\n\n")
+			}
 		case "heading_2":
-			result.WriteString("## " + text + "\n\n")
+			if b.IsToggleable {
+				result.WriteString(detailsMarkdown(headingSummary(2, text), b.Children, trailingChildPages))
+			} else {
+				result.WriteString("## " + escapeClosingHashes(text) + "\n\n")
+			}
 		case "heading_3":
-			result.WriteString("### " + text + "\n\n")
+			if b.IsToggleable {
+				result.WriteString(detailsMarkdown(headingSummary(3, text), b.Children, trailingChildPages))
+			} else {
+				result.WriteString("### " + escapeClosingHashes(text) + "\n\n")
+			}
 		case "paragraph":
 			result.WriteString(text + "\n\n")
+			writeChildrenSection(&result, b.Children, trailingChildPages)
 		case "bulleted_list_item":
-			result.WriteString("- " + text + "\n")
-			// Handle children (checks both block level and content level)
-			childBlocks := extractChildBlocksFromBlock(b, "bulleted_list_item")
-			if len(childBlocks) > 0 {
-				childMd := blocksToMarkdownIndented(childBlocks, "  ", trailingChildPages)
-				result.WriteString(childMd)
-			}
+			result.WriteString("- " + indentContinuation(text, "  ") + "\n")
+			writeListChildren(&result, b.Children, "  ", trailingChildPages)
 		case "numbered_list_item":
-			result.WriteString(fmt.Sprintf("%d. %s\n", listNum, text))
+			result.WriteString(fmt.Sprintf("%d. %s\n", listNum, indentContinuation(text, "   ")))
 			listNum++
-			// Handle children (checks both block level and content level)
-			childBlocks := extractChildBlocksFromBlock(b, "numbered_list_item")
-			if len(childBlocks) > 0 {
-				childMd := blocksToMarkdownIndented(childBlocks, "   ", trailingChildPages)
-				result.WriteString(childMd)
-			}
+			writeListChildren(&result, b.Children, "   ", trailingChildPages)
 		case "to_do":
 			check := " "
-			if todoBlock, ok := b["to_do"].(map[string]any); ok {
-				if checked, ok := todoBlock["checked"].(bool); ok && checked {
-					check = "x"
-				}
+			if b.Checked {
+				check = "x"
 			}
-			result.WriteString(fmt.Sprintf("- [%s] %s\n", check, text))
+			result.WriteString(fmt.Sprintf("- [%s] %s\n", check, indentContinuation(text, "  ")))
+			writeListChildren(&result, b.Children, "  ", trailingChildPages)
 		case "quote":
-			result.WriteString("> " + text + "\n\n")
+			result.WriteString(quoteLines(text, b.Children, trailingChildPages))
 		case "callout":
-			result.WriteString("> " + text + "\n\n")
+			marker := calloutMarker(b)
+			if text != "" {
+				marker += "\n" + text
+			}
+			result.WriteString(quoteLines(marker, b.Children, trailingChildPages))
+		case "toggle":
+			result.WriteString(detailsMarkdown(text, b.Children, trailingChildPages))
+		case "column_list":
+			result.WriteString(columnsMarkdown(b, trailingChildPages))
+		case "synced_block":
+			result.WriteString(syncedBlockMarkdown(b, trailingChildPages))
+		case "column":
+			// Columns are written by their column list
+			result.WriteString(BlocksToMarkdownWithChildPages(b.Children, trailingChildPages))
 		case "code":
-			lang := ""
-			if codeBlock, ok := b["code"].(map[string]any); ok {
-				if l, ok := codeBlock["language"].(string); ok {
-					lang = l
-				}
-			}
-			result.WriteString("```" + lang + "\n" + text + "\n```\n\n")
+			// Code is written verbatim, without formatting or escaping
+			code := richTextPlain(b.RichText)
+			fence := codeFence(code)
+			result.WriteString(fence + b.Language + "\n" + code + "\n" + fence + "\n\n")
+		case "image", "video", "audio", "file", "pdf":
+			if md := mediaMarkdown(b); md != "" {
+				result.WriteString(md + "\n\n")
+			}
+		case "bookmark", "embed", "link_preview":
+			if md := linkBlockMarkdown(b); md != "" {
+				result.WriteString(md + "\n\n")
+			}
+		case "equation":
+			result.WriteString(mathBlockMarkdown(b.Expression))
+		case "table_of_contents":
+			result.WriteString(tocPlaceholder + "\n\n")
+		case "breadcrumb":
+			result.WriteString(breadcrumbPlaceholder + "\n\n")
+		case "link_to_page":
+			if md := linkToPageMarkdown(b); md != "" {
+				result.WriteString(md + "\n\n")
+			}
+		case "child_database":
+			result.WriteString(childDatabaseMarkdown(b) + "\n\n")
 		case "divider":
 			result.WriteString("---\n\n")
 		case "child_page":
 			// Handle child pages as mentions (links to the page)
 			// Trailing child pages are skipped - they'll be at the bottom after restore anyway
-			pageID, _ := b["id"].(string)
+			pageID := b.ID
 			if pageID != "" {
 				// Skip trailing child pages entirely
 				if trailingChildPages != nil && trailingChildPages[pageID] {
@@ -385,14 +472,12 @@ func BlocksToMarkdownWithChildPages(blocks []map[string]any, trailingChildPages
 					continue
 				}
 				// Non-trailing: output as mention link
-				if childPage, ok := b["child_page"].(map[string]any); ok {
-					title, _ := childPage["title"].(string)
-					if title == "" {
-						title = "Untitled"
-					}
-					// Output as mention: [@Title](notion://page-id)
-					result.WriteString(fmt.Sprintf("[@%s](notion://%s)\n\n", title, pageID))
+				title := b.Title
+				if title == "" {
+					title = "Untitled"
 				}
+				// Output as mention: [@Title](notion://page-id)
+				result.WriteString(fmt.Sprintf("[@%s](notion://%s)\n\n", title, pageID))
 			}
 		case "table":
 			result.WriteString(extractTableMarkdown(b) + "\n")
@@ -402,6 +487,7 @@ func BlocksToMarkdownWithChildPages(blocks []map[string]any, trailingChildPages
 		default:
 			if text != "" {
 				result.WriteString(text + "\n\n")
+				writeChildrenSection(&result, b.Children, trailingChildPages)
 			}
 		}
 
@@ -417,206 +503,115 @@ func BlocksToMarkdownWithChildPages(blocks []map[string]any, trailingChildPages
 	return result.String()
 }
 
-func formatBlockComments(b map[string]any) string {
-	comments, ok := b["_comments"].([]map[string]any)
-	if !ok || len(comments) == 0 {
-		return ""
+// writeListChildren writes the children of a list item indented under it.
+// Children that are not list items are separated from the item text by a
+// blank line so they don't read as a continuation of it.
+func writeListChildren(result *strings.Builder, children []Block, indent string, trailingChildPages map[string]bool) {
+	if len(children) == 0 {
+		return
 	}
-
-	var result strings.Builder
-	for _, c := range comments {
-		author, _ := c["author"].(string)
-		content, _ := c["content"].(string)
-		date, _ := c["created_at"].(string)
-		if author == "" {
-			author = "Unknown"
-		}
-		result.WriteString(fmt.Sprintf("> **%s** *(%s)*: %s\n\n", author, date, content))
+	if !isListItemType(children[0].Type) {
+		result.WriteString("\n")
 	}
-	return result.String()
+	result.WriteString(blocksToMarkdownIndented(children, indent, trailingChildPages))
 }
 
-func extractTableComments(b map[string]any) string {
-	tableData, ok := b["table"].(map[string]any)
-	if !ok {
-		return ""
-	}
-
-	children, ok := tableData["children"].([]any)
-	if !ok {
-		return ""
+// quoteLines writes text and children as a blockquote, with every line
+// prefixed by "> ".
+func quoteLines(text string, children []Block, trailingChildPages map[string]bool) string {
+	md := text + "\n"
+	if len(children) > 0 {
+		md += "\n" + BlocksToMarkdownWithChildPages(children, trailingChildPages)
 	}
 
 	var result strings.Builder
-	for _, child := range children {
-		if row, ok := child.(map[string]any); ok {
-			if comments, ok := row["_comments"].([]map[string]any); ok && len(comments) > 0 {
-				for _, c := range comments {
-					author, _ := c["author"].(string)
-					content, _ := c["content"].(string)
-					date, _ := c["created_at"].(string)
-					if author == "" {
-						author = "Unknown"
-					}
-					result.WriteString(fmt.Sprintf("> **%s** *(%s)*: %s\n\n", author, date, content))
-				}
-			}
+	for _, line := range strings.Split(strings.TrimRight(md, "\n"), "\n") {
+		if line == "" {
+			result.WriteString(">\n")
+		} else {
+			result.WriteString("> " + line + "\n")
 		}
 	}
+	result.WriteString("\n")
 	return result.String()
 }
 
-func extractBlockText(b map[string]any, blockType string) string {
-	var richText []any
-
-	if content, ok := b[blockType].(map[string]any); ok {
-		// Handle both []any (from API) and []map[string]any (from local creation)
-		if rt, ok := content["rich_text"].([]any); ok {
-			richText = rt
-		} else if rtMaps, ok := content["rich_text"].([]map[string]any); ok {
-			// Convert []map[string]any to []any
-			richText = make([]any, len(rtMaps))
-			for i, m := range rtMaps {
-				richText[i] = m
-			}
-		}
-	}
+func isListItemType(blockType string) bool {
+	return blockType == "bulleted_list_item" || blockType == "numbered_list_item" || blockType == "to_do"
+}
 
-	return richTextToMarkdown(richText)
+// indentContinuation indents every line after the first, so multi-line
+// list item text stays inside the item.
+func indentContinuation(text, indent string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+indent)
 }
 
-// richTextToMarkdown converts Notion rich_text array to markdown string,
-// preserving formatting, links, and mentions.
-func richTextToMarkdown(richText []any) string {
-	var text strings.Builder
-	for _, item := range richText {
-		rt, ok := item.(map[string]any)
-		if !ok {
-			continue
+// codeFence returns a backtick fence longer than any backtick run in code.
+func codeFence(code string) string {
+	longest, run := 0, 0
+	for i := 0; i < len(code); i++ {
+		if code[i] == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
 		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
 
-		itemType, _ := rt["type"].(string)
-		content := ""
-		var linkURL string
-
-		switch itemType {
-		case "text":
-			// Handle both map[string]any (from API) and map[string]string (from local creation)
-			if textObj, ok := rt["text"].(map[string]any); ok {
-				content, _ = textObj["content"].(string)
-				if link, ok := textObj["link"].(map[string]any); ok {
-					linkURL, _ = link["url"].(string)
-				}
-			} else if textObj, ok := rt["text"].(map[string]string); ok {
-				content = textObj["content"]
-				if link, ok := textObj["link"]; ok {
-					linkURL = link
-				}
-			}
-		case "mention":
-			if mention, ok := rt["mention"].(map[string]any); ok {
-				mentionType, _ := mention["type"].(string)
-				switch mentionType {
-				case "page":
-					if page, ok := mention["page"].(map[string]any); ok {
-						pageID, _ := page["id"].(string)
-						plainText, _ := rt["plain_text"].(string)
-						if plainText == "" {
-							plainText = "Page"
-						}
-						// Format as Notion page mention: [@Page Title](notion://page-id)
-						text.WriteString(fmt.Sprintf("[@%s](notion://%s)", plainText, pageID))
-						continue
-					}
-				case "user":
-					plainText, _ := rt["plain_text"].(string)
-					text.WriteString(plainText)
-					continue
-				case "date":
-					plainText, _ := rt["plain_text"].(string)
-					text.WriteString(plainText)
-					continue
-				default:
-					plainText, _ := rt["plain_text"].(string)
-					text.WriteString(plainText)
-					continue
-				}
-			}
-			// Fallback to plain_text
-			if pt, ok := rt["plain_text"].(string); ok {
-				content = pt
-			}
-		default:
-			// Fallback to plain_text for unknown types
-			if pt, ok := rt["plain_text"].(string); ok {
-				content = pt
-			}
-		}
+func formatBlockComments(b Block) string {
+	if len(b.Comments) == 0 {
+		return ""
+	}
 
-		// Apply annotations (bold, italic, strikethrough, underline, code)
-		// Handle both map[string]any (from API) and map[string]bool (from local creation)
-		var code, bold, italic, strikethrough bool
-		if annotations, ok := rt["annotations"].(map[string]any); ok {
-			code, _ = annotations["code"].(bool)
-			bold, _ = annotations["bold"].(bool)
-			italic, _ = annotations["italic"].(bool)
-			strikethrough, _ = annotations["strikethrough"].(bool)
-		} else if annotations, ok := rt["annotations"].(map[string]bool); ok {
-			code = annotations["code"]
-			bold = annotations["bold"]
-			italic = annotations["italic"]
-			strikethrough = annotations["strikethrough"]
-		}
-		if code {
-			content = "`" + content + "`"
-		}
-		if bold {
-			content = "**" + content + "**"
-		}
-		if italic {
-			content = "*" + content + "*"
-		}
-		if strikethrough {
-			content = "~~" + content + "~~"
+	var result strings.Builder
+	for _, c := range b.Comments {
+		author := c.Author
+		if author == "" {
+			author = "Unknown"
 		}
+		result.WriteString(formatComment(author, c.CreatedAt.Format("Jan 2, 2006"), c.Content))
+	}
+	return result.String()
+}
 
-		// Apply link if present
-		if linkURL != "" {
-			content = "[" + content + "](" + linkURL + ")"
-		}
+// formatComment writes a comment as a blockquote that starts with its
+// author and date. Every line of a multi-line comment stays in the quote.
+func formatComment(author, date, content string) string {
+	quoted := strings.ReplaceAll(content, "\n", "\n> ")
+	quoted = strings.ReplaceAll(quoted, "\n> \n", "\n>\n")
+	return fmt.Sprintf("> **%s** *(%s)*: %s\n\n", author, date, quoted)
+}
 
-		text.WriteString(content)
-	}
-	return text.String()
+// isCommentLine reports whether a line inside a blockquote starts a
+// comment written by formatComment.
+func isCommentLine(line string) bool {
+	return commentLinePattern.MatchString(line)
 }
 
-func extractTableMarkdown(b map[string]any) string {
-	tableData, ok := b["table"].(map[string]any)
-	if !ok {
-		return ""
-	}
+var commentLinePattern = regexp.MustCompile(`^\*\*[^*\n]+\*\* \*\([^)\n]*\)\*: `)
 
-	children, ok := tableData["children"].([]any)
-	if !ok {
-		return ""
+func extractTableComments(b Block) string {
+	var result strings.Builder
+	for _, row := range b.Children {
+		result.WriteString(formatBlockComments(row))
 	}
+	return result.String()
+}
 
+func extractTableMarkdown(b Block) string {
 	var rows [][]string
-	for _, child := range children {
-		if row, ok := child.(map[string]any); ok {
-			if rowData, ok := row["table_row"].(map[string]any); ok {
-				if cells, ok := rowData["cells"].([]any); ok {
-					var rowCells []string
-					for _, cell := range cells {
-						if cellItems, ok := cell.([]any); ok {
-							// Use richTextToMarkdown to preserve formatting in table cells
-							rowCells = append(rowCells, richTextToMarkdown(cellItems))
-						}
-					}
-					rows = append(rows, rowCells)
-				}
-			}
+	for _, row := range b.Children {
+		if row.Type != "table_row" {
+			continue
+		}
+		var rowCells []string
+		for _, cell := range row.Cells {
+			// Preserve formatting in table cells, with pipes escaped
+			rowCells = append(rowCells, tableCellMarkdown(cell))
 		}
+		rows = append(rows, rowCells)
 	}
 
 	if len(rows) == 0 {
@@ -637,230 +632,6 @@ func extractTableMarkdown(b map[string]any) string {
 	return result.String()
 }
 
-func parseInlineMarkdown(text string) []map[string]any {
-	var result []map[string]any
-	i := 0
-
-	for i < len(text) {
-		// Bold: **text**
-		if i+1 < len(text) && text[i] == '*' && text[i+1] == '*' {
-			end := findClosing(text, i+2, "**")
-			if end > 0 {
-				result = append(result, map[string]any{
-					"type":        "text",
-					"text":        map[string]string{"content": text[i+2 : end]},
-					"annotations": map[string]bool{"bold": true},
-				})
-				i = end + 2
-				continue
-			}
-		}
-
-		// Italic: *text*
-		if text[i] == '*' && (i+1 >= len(text) || text[i+1] != '*') {
-			end := findClosingSingle(text, i+1, '*')
-			if end > 0 {
-				result = append(result, map[string]any{
-					"type":        "text",
-					"text":        map[string]string{"content": text[i+1 : end]},
-					"annotations": map[string]bool{"italic": true},
-				})
-				i = end + 1
-				continue
-			}
-		}
-
-		// Inline code: `text`
-		if text[i] == '`' {
-			end := findClosingSingle(text, i+1, '`')
-			if end > 0 {
-				result = append(result, map[string]any{
-					"type":        "text",
-					"text":        map[string]string{"content": text[i+1 : end]},
-					"annotations": map[string]bool{"code": true},
-				})
-				i = end + 1
-				continue
-			}
-		}
-
-		// Link: [text](url) or page mention: [@Title](notion://page-id)
-		if text[i] == '[' {
-			closeBracket := findClosingSingle(text, i+1, ']')
-			if closeBracket > 0 && closeBracket+1 < len(text) && text[closeBracket+1] == '(' {
-				closeParen := findClosingSingle(text, closeBracket+2, ')')
-				if closeParen > 0 {
-					linkText := text[i+1 : closeBracket]
-					linkURL := text[closeBracket+2 : closeParen]
-
-					// Check for page mention: [@Title](notion://page-id)
-					if strings.HasPrefix(linkURL, "notion://") && strings.HasPrefix(linkText, "@") {
-						pageID := strings.TrimPrefix(linkURL, "notion://")
-						result = append(result, map[string]any{
-							"type": "mention",
-							"mention": map[string]any{
-								"type": "page",
-								"page": map[string]any{
-									"id": pageID,
-								},
-							},
-						})
-						i = closeParen + 1
-						continue
-					}
-
-					// Regular link
-					result = append(result, map[string]any{
-						"type": "text",
-						"text": map[string]any{
-							"content": linkText,
-							"link":    map[string]string{"url": linkURL},
-						},
-					})
-					i = closeParen + 1
-					continue
-				}
-			}
-		}
-
-		// Regular text - find next special char or end of string
-		start := i
-		for i < len(text) && text[i] != '*' && text[i] != '`' && text[i] != '[' {
-			i++
-		}
-		if i > start {
-			result = append(result, map[string]any{
-				"type": "text",
-				"text": map[string]string{"content": text[start:i]},
-			})
-		} else {
-			// Special char without valid closing - treat as literal text and advance
-			result = append(result, map[string]any{
-				"type": "text",
-				"text": map[string]string{"content": string(text[i])},
-			})
-			i++
-		}
-	}
-
-	if len(result) == 0 {
-		return []map[string]any{
-			{"type": "text", "text": map[string]string{"content": text}},
-		}
-	}
-	return result
-}
-
-func parseMarkdownTable(rows []string) map[string]any {
-	if len(rows) < 2 {
-		return nil
-	}
-
-	parseCells := func(row string) []string {
-		row = strings.TrimSpace(row)
-		if len(row) > 0 && row[0] == '|' {
-			row = row[1:]
-		}
-		if len(row) > 0 && row[len(row)-1] == '|' {
-			row = row[:len(row)-1]
-		}
-		parts := strings.Split(row, "|")
-		var cells []string
-		for _, p := range parts {
-			cells = append(cells, strings.TrimSpace(p))
-		}
-		return cells
-	}
-
-	isSeparator := func(row string) bool {
-		row = strings.TrimSpace(row)
-		for _, c := range row {
-			if c != '|' && c != '-' && c != ':' && c != ' ' {
-				return false
-			}
-		}
-		return strings.Contains(row, "-")
-	}
-
-	var dataRows [][]string
-	for _, row := range rows {
-		if !isSeparator(row) {
-			cells := parseCells(row)
-			if len(cells) > 0 {
-				dataRows = append(dataRows, cells)
-			}
-		}
-	}
-
-	if len(dataRows) == 0 {
-		return nil
-	}
-
-	tableWidth := len(dataRows[0])
-
-	var tableRowBlocks []map[string]any
-	for _, cells := range dataRows {
-		for len(cells) < tableWidth {
-			cells = append(cells, "")
-		}
-		var notionCells [][]map[string]any
-		for _, cell := range cells[:tableWidth] {
-			notionCells = append(notionCells, parseInlineMarkdown(cell))
-		}
-		tableRowBlocks = append(tableRowBlocks, map[string]any{
-			"object": "block",
-			"type":   "table_row",
-			"table_row": map[string]any{
-				"cells": notionCells,
-			},
-		})
-	}
-
-	return map[string]any{
-		"object": "block",
-		"type":   "table",
-		"table": map[string]any{
-			"table_width":       tableWidth,
-			"has_column_header": true,
-			"has_row_header":    false,
-			"children":          tableRowBlocks,
-		},
-	}
-}
-
-func findClosing(text string, pos int, marker string) int {
-	for i := pos; i <= len(text)-len(marker); i++ {
-		if text[i:i+len(marker)] == marker {
-			return i
-		}
-	}
-	return -1
-}
-
-func findClosingSingle(text string, pos int, marker byte) int {
-	for i := pos; i < len(text); i++ {
-		if text[i] == marker {
-			return i
-		}
-	}
-	return -1
-}
-
-func splitLines(s string) []string {
-	var lines []string
-	start := 0
-	for i := 0; i < len(s); i++ {
-		if s[i] == '\n' {
-			lines = append(lines, s[start:i])
-			start = i + 1
-		}
-	}
-	if start < len(s) {
-		lines = append(lines, s[start:])
-	}
-	return lines
-}
-
 // mapLanguageToNotion maps markdown language hints to Notion's expected language values.
 // Notion has a specific list of supported languages.
 func mapLanguageToNotion(lang string) string {
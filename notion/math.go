@@ -0,0 +1,209 @@
+package notion
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Equations are written as LaTeX between dollar signs, following pandoc's
+// rules so that prices and other dollar signs aren't read as math:
+//
+//	$$
+//	E = mc^2
+//	$$
+//
+//	Inline math: $a^2 + b^2 = c^2$.
+//
+// An inline opening "$" must be followed by a non-space character, and the
+// closing "$" must follow a non-space character and not be followed by a
+// digit. "$5 and $10" is plain text.
+//
+// An inline expression that can't be written that way, because it holds a
+// "$" or is only spaces, is wrapped in backticks inside the dollar signs,
+// as GitLab does, and read literally:
+//
+//	$`\text{costs $5}`$
+
+var kindMathBlock = ast.NewNodeKind("MathBlock")
+
+// mathBlock is a $$ display equation. Its lines hold the expression.
+type mathBlock struct {
+	ast.BaseBlock
+}
+
+func (n *mathBlock) Kind() ast.NodeKind { return kindMathBlock }
+func (n *mathBlock) IsRaw() bool        { return true }
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+var kindMathInline = ast.NewNodeKind("MathInline")
+
+// mathInline is a $...$ inline equation.
+type mathInline struct {
+	ast.BaseInline
+	expression string
+}
+
+func (n *mathInline) Kind() ast.NodeKind { return kindMathInline }
+func (n *mathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Expression": n.expression}, nil)
+}
+
+// mathBlockParser parses $$ blocks: either "$$" lines around the
+// expression, or "$$expression$$" on one line.
+type mathBlockParser struct{}
+
+// singleLineMathKey marks a mathBlock that was complete on its opening line.
+var singleLineMathKey = parser.NewContextKey()
+
+func (p *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	rest := bytes.TrimRight(line[pos+2:], " \t\r\n")
+	node := &mathBlock{}
+	if len(rest) == 0 {
+		reader.AdvanceToEOL()
+		return node, parser.NoChildren
+	}
+	if len(rest) < 2 || !bytes.HasSuffix(rest, []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	start := segment.Start + pos + 2
+	node.Lines().Append(text.NewSegment(start, start+len(rest)-2))
+	pc.Set(singleLineMathKey, node)
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	if pc.Get(singleLineMathKey) == node {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	trimmed := bytes.TrimRight(line, " \t\r\n")
+	if bytes.HasSuffix(trimmed, []byte("$$")) {
+		// The closing $$ may end the last line of the expression
+		if content := bytes.TrimSpace(trimmed[:len(trimmed)-2]); len(content) > 0 {
+			node.Lines().Append(text.NewSegment(segment.Start, segment.Start+len(trimmed)-2))
+		}
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	seg := segment
+	seg.ForceNewline = true
+	node.Lines().Append(seg)
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	if pc.Get(singleLineMathKey) == node {
+		pc.Set(singleLineMathKey, nil)
+	}
+}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool { return true }
+func (p *mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+// mathInlineParser parses $...$ inline equations.
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if end := mathInlineCodeEnd(line); end >= 0 {
+		block.Advance(end + 1)
+		return &mathInline{expression: string(line[2 : end-1])}
+	}
+	end := mathInlineEnd(line, true)
+	if end < 0 {
+		return nil
+	}
+	block.Advance(end + 1)
+	return &mathInline{expression: string(line[1:end])}
+}
+
+// mathInlineCodeEnd returns the index of the "$" that closes an inline
+// equation written as $`...`$, or -1 if s doesn't start one.
+func mathInlineCodeEnd(s []byte) int {
+	if !bytes.HasPrefix(s, []byte("$`")) {
+		return -1
+	}
+	i := bytes.Index(s[2:], []byte("`$"))
+	if i < 0 {
+		return -1
+	}
+	return i + 3
+}
+
+// mathInlineEnd returns the index of the "$" that closes an inline
+// equation opened by s[0], or -1 if s doesn't start one. With escapes, a
+// backslash hides the character after it, as in markdown source.
+func mathInlineEnd(s []byte, escapes bool) int {
+	if len(s) < 3 || s[0] != '$' || isSpaceByte(s[1]) || s[1] == '$' {
+		return -1
+	}
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && escapes:
+			i++
+		case s[i] == '$' && i > 1 && !isSpaceByte(s[i-1]) && (i+1 == len(s) || s[i+1] < '0' || s[i+1] > '9'):
+			return i
+		}
+	}
+	return -1
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// mayFormMath reports whether plain text contains dollar signs that would
+// be read as an inline equation, so they need escaping. Backslashes in the
+// text are escaped themselves when written, so they don't hide anything.
+func mayFormMath(s string) bool {
+	for i := strings.IndexByte(s, '$'); i >= 0 && i < len(s); {
+		if mathInlineEnd([]byte(s[i:]), false) >= 0 || mathInlineCodeEnd([]byte(s[i:])) >= 0 {
+			return true
+		}
+		next := strings.IndexByte(s[i+1:], '$')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return false
+}
+
+// inlineMathMarkdown writes an inline equation.
+func inlineMathMarkdown(expression string) string {
+	expression = strings.ReplaceAll(expression, "\n", " ")
+	if trimmed := strings.TrimSpace(expression); trimmed != "" {
+		expression = trimmed
+	}
+	md := "$" + expression + "$"
+	if mathInlineEnd([]byte(md), true) != len(md)-1 {
+		md = "$`" + expression + "`$"
+	}
+	return md
+}
+
+// mathBlockMarkdown writes an equation block.
+func mathBlockMarkdown(expression string) string {
+	return "$$\n" + strings.Trim(expression, "\n") + "\n$$\n\n"
+}
@@ -0,0 +1,228 @@
+package notion
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Media blocks (image, video, audio, file and pdf) are written as markdown
+// images and links:
+//
+//	![Caption](assets/diagram-1a2b3c4d.png)
+//	[Quarterly report](assets/report-5e6f7a8b.pdf)
+//	[Demo](https://example.com/demo.mp4) <!-- video -->
+//
+// Images use image syntax. Other local files are plain links, and their
+// block type comes from the file extension when read back. A marker comment
+// names the type of everything else. A paragraph holding nothing but one of
+// these reads back as the media block.
+//
+// Bookmarks, embeds and link previews are links with a marker too. Without
+// a caption the link is just the URL:
+//
+//	<https://example.com/dashboard> <!-- bookmark -->
+//	[Design doc](https://example.com/doc) <!-- embed -->
+
+// mediaMarkdown writes a media block, or returns "" if it has no file.
+func mediaMarkdown(b Block) string {
+	dest := b.AssetPath
+	if dest == "" {
+		dest = b.File.URL()
+	}
+	if dest == "" {
+		return ""
+	}
+
+	caption := renderRichText(b.Caption, &textEscaper{})
+	if b.Type == "image" {
+		return "![" + caption + "](" + linkDestination(dest) + ")"
+	}
+	if caption == "" {
+		caption = escapeLinkText(fileNameFromURL(dest))
+	}
+	link := "[" + caption + "](" + linkDestination(dest) + ")"
+	if b.AssetPath != "" && mediaTypeForPath(dest) == b.Type {
+		return link
+	}
+	return link + " <!-- " + b.Type + " -->"
+}
+
+// linkBlockTypes are the block types that are a link to a web page.
+var linkBlockTypes = map[string]bool{
+	"bookmark":     true,
+	"embed":        true,
+	"link_preview": true,
+}
+
+// linkBlockMarkdown writes a bookmark, embed or link preview.
+func linkBlockMarkdown(b Block) string {
+	if b.URL == "" {
+		return ""
+	}
+	caption := renderRichText(b.Caption, &textEscaper{})
+	link := "[" + caption + "](" + linkDestination(b.URL) + ")"
+	if caption == "" {
+		if isAutolink(b.URL, b.URL) {
+			link = "<" + b.URL + ">"
+		} else {
+			link = "[" + escapeLinkText(b.URL) + "](" + linkDestination(b.URL) + ")"
+		}
+	}
+	return link + " <!-- " + b.Type + " -->"
+}
+
+// bookmarkLinkPreviews turns link previews into bookmarks. The API returns
+// link previews but can't create them.
+func bookmarkLinkPreviews(blocks []Block) {
+	for i := range blocks {
+		if blocks[i].Type == "link_preview" {
+			blocks[i].Type = "bookmark"
+		}
+		bookmarkLinkPreviews(blocks[i].Children)
+	}
+}
+
+// paragraphToMedia reads a paragraph holding only an image, a link to a
+// local file, or a link with a marker as a media, bookmark, embed, link
+// preview or link_to_page block.
+func paragraphToMedia(p ast.Node, source []byte) (Block, bool) {
+	var nodes []ast.Node
+	for n := p.FirstChild(); n != nil; n = n.NextSibling() {
+		if t, ok := n.(*ast.Text); ok && strings.TrimSpace(string(t.Segment.Value(source))) == "" {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 0 || len(nodes) > 2 {
+		return Block{}, false
+	}
+
+	switch node := nodes[0].(type) {
+	case *ast.Image:
+		if len(nodes) > 1 {
+			return Block{}, false
+		}
+		return newMediaBlock("image", unescapeMarkdown(node.Destination), inlineToRichText(node, source)), true
+
+	case *ast.AutoLink:
+		if len(nodes) < 2 {
+			return Block{}, false
+		}
+		blockType := mediaMarker(nodes[1], source)
+		if linkBlockTypes[blockType] {
+			return Block{Type: blockType, URL: string(node.URL(source))}, true
+		}
+		if blockType != "" {
+			return newMediaBlock(blockType, string(node.URL(source)), nil), true
+		}
+
+	case *ast.Link:
+		dest := unescapeMarkdown(node.Destination)
+		if len(nodes) == 2 {
+			title := richTextPlain(inlineToRichText(node, source))
+			if link, ok := linkToPageBlock(dest, markerComment(nodes[1], source), title); ok {
+				return link, true
+			}
+		}
+		blockType := ""
+		if len(nodes) == 2 {
+			blockType = mediaMarker(nodes[1], source)
+		} else if isLocalFile(dest) {
+			blockType = mediaTypeForPath(dest)
+		}
+		if blockType == "" {
+			return Block{}, false
+		}
+		caption := inlineToRichText(node, source)
+		if linkBlockTypes[blockType] {
+			if richTextPlain(caption) == dest {
+				caption = nil
+			}
+			return Block{Type: blockType, URL: dest, Caption: caption}, true
+		}
+		// A link without a caption shows the file name
+		if richTextPlain(caption) == fileNameFromURL(dest) {
+			caption = nil
+		}
+		return newMediaBlock(blockType, dest, caption), true
+	}
+	return Block{}, false
+}
+
+// newMediaBlock creates a media block for a local file or a URL.
+func newMediaBlock(blockType, dest string, caption []RichText) Block {
+	block := Block{Type: blockType, Caption: caption}
+	if isLocalFile(dest) {
+		block.AssetPath = dest
+	} else {
+		block.File = &File{Type: "external", External: &FileRef{URL: dest}}
+	}
+	return block
+}
+
+// mediaMarker returns the block type named by a marker comment such as
+// "<!-- video -->" or "<!-- bookmark -->", or "" if n isn't one.
+func mediaMarker(n ast.Node, source []byte) string {
+	if name := markerComment(n, source); mediaBlockTypes[name] || linkBlockTypes[name] {
+		return name
+	}
+	return ""
+}
+
+// markerComment returns the text of an inline HTML comment, or "" if n
+// isn't one.
+func markerComment(n ast.Node, source []byte) string {
+	html, ok := n.(*ast.RawHTML)
+	if !ok {
+		return ""
+	}
+	name, ok := strings.CutPrefix(rawHTMLText(html, source), "<!--")
+	if !ok {
+		return ""
+	}
+	name, ok = strings.CutSuffix(name, "-->")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
+// isLocalFile reports whether a link destination is a file path rather
+// than a URL.
+func isLocalFile(dest string) bool {
+	if dest == "" || strings.HasPrefix(dest, "#") {
+		return false
+	}
+	u, err := url.Parse(dest)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// mediaTypeForPath returns the block type for a local file, based on its
+// extension, or "" for files that are documents to link to rather than
+// attachments.
+func mediaTypeForPath(p string) string {
+	ext := strings.ToLower(path.Ext(p))
+	switch ext {
+	case "", ".md", ".markdown", ".htm", ".html":
+		return ""
+	case ".pdf":
+		return "pdf"
+	case ".mp4", ".mov", ".webm", ".m4v", ".avi", ".mkv", ".mpg", ".mpeg", ".wmv", ".flv":
+		return "video"
+	case ".mp3", ".wav", ".ogg", ".oga", ".m4a", ".aac", ".flac", ".wma", ".mid", ".midi":
+		return "audio"
+	}
+	return "file"
+}
+
+// fileNameFromURL returns the last path element of a URL or file path,
+// without any query string.
+func fileNameFromURL(dest string) string {
+	if u, err := url.Parse(dest); err == nil {
+		dest = u.Path
+	}
+	return path.Base(dest)
+}
@@ -0,0 +1,218 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Blocks that have no markdown equivalent are written as placeholders:
+//
+//	[[toc]]
+//	[[breadcrumb]]
+//	[Roadmap](notion://1a2b3c4d-...) <!-- link_to_page -->
+//	<!-- child_database: 5e6f7a8b-... Tasks -->
+//
+// A link to a database has the marker "<!-- link_to_page: database -->".
+// Child databases can't be created through the API, so on push they stay
+// where they are on the page, and the comment only marks their position.
+
+const (
+	tocPlaceholder        = "[[toc]]"
+	breadcrumbPlaceholder = "[[breadcrumb]]"
+	childDatabasePrefix   = "<!-- child_database:"
+)
+
+// linkToPageMarkdown writes a link_to_page block as a link to the page or
+// database with a marker.
+func linkToPageMarkdown(b Block) string {
+	if b.LinkTo == nil || b.LinkTo.ID() == "" {
+		return ""
+	}
+	title := b.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	marker := "<!-- link_to_page -->"
+	if b.LinkTo.Type == "database_id" {
+		marker = "<!-- link_to_page: database -->"
+	}
+	return fmt.Sprintf("[%s](notion://%s) %s", escapeLinkText(title), b.LinkTo.ID(), marker)
+}
+
+// linkToPageBlock reads a link to notion://ID with a link_to_page marker.
+// The link text is kept as the title, though Notion shows the target's own.
+func linkToPageBlock(dest, marker, title string) (Block, bool) {
+	id, ok := strings.CutPrefix(dest, "notion://")
+	if !ok || id == "" {
+		return Block{}, false
+	}
+	// Links rewritten from relative paths are written as mentions
+	title = strings.TrimPrefix(title, "@")
+	switch marker {
+	case "link_to_page":
+		return Block{Type: "link_to_page", Title: title, LinkTo: &LinkRef{Type: "page_id", PageID: id}}, true
+	case "link_to_page: database":
+		return Block{Type: "link_to_page", Title: title, LinkTo: &LinkRef{Type: "database_id", DatabaseID: id}}, true
+	}
+	return Block{}, false
+}
+
+// childDatabaseMarkdown writes the placeholder for a child database.
+func childDatabaseMarkdown(b Block) string {
+	title := strings.TrimSpace(strings.ReplaceAll(b.Title, "-->", ""))
+	if title == "" {
+		return childDatabasePrefix + " " + b.ID + " -->"
+	}
+	return childDatabasePrefix + " " + b.ID + " " + title + " -->"
+}
+
+// childDatabaseToBlock reads a child database placeholder.
+func childDatabaseToBlock(raw string) (Block, bool) {
+	rest, ok := strings.CutPrefix(raw, childDatabasePrefix)
+	if !ok {
+		return Block{}, false
+	}
+	rest, ok = strings.CutSuffix(rest, "-->")
+	if !ok {
+		return Block{}, false
+	}
+	id, title, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	if id == "" {
+		return Block{}, false
+	}
+	return Block{Type: "child_database", ID: id, Title: strings.TrimSpace(title)}, true
+}
+
+// paragraphToPlaceholder reads a paragraph that is nothing but [[toc]] or
+// [[breadcrumb]].
+func paragraphToPlaceholder(p ast.Node, source []byte) (Block, bool) {
+	switch strings.TrimSpace(codeText(p, source)) {
+	case tocPlaceholder:
+		return Block{Type: "table_of_contents"}, true
+	case breadcrumbPlaceholder:
+		return Block{Type: "breadcrumb"}, true
+	}
+	return Block{}, false
+}
+
+// resolveLinkTitles looks up the titles of the pages and databases that
+// link_to_page blocks point to.
+func (c *Client) resolveLinkTitles(blocks []Block) {
+	for i := range blocks {
+		b := &blocks[i]
+		if b.Type == "link_to_page" && b.LinkTo != nil {
+			if b.LinkTo.Type == "database_id" {
+				b.Title = c.databaseTitle(b.LinkTo.DatabaseID)
+			} else if page := c.fetchRelatedPage(b.LinkTo.PageID); page.Err == nil {
+				b.Title = page.Title
+			}
+		}
+		c.resolveLinkTitles(b.Children)
+	}
+}
+
+// databaseTitle returns the title of a database, or "" if it can't be read.
+func (c *Client) databaseTitle(databaseID string) string {
+	resp, err := c.doRequest("GET", fmt.Sprintf("%s/databases/%s", c.apiBase, databaseID), nil)
+	if err != nil {
+		debugLog("databaseTitle: %s: %v", databaseID, err)
+		return ""
+	}
+	var db struct {
+		Title []RichText `json:"title"`
+	}
+	if err := json.Unmarshal(resp, &db); err != nil {
+		return ""
+	}
+	var title strings.Builder
+	for _, rt := range db.Title {
+		title.WriteString(rt.PlainText)
+	}
+	return title.String()
+}
+
+// keepChildDatabases keeps a page's child databases in place on push,
+// since the API can't create them. current are the page's top-level
+// blocks, and the IDs of its child databases are added to keep. The first
+// top-level placeholder for each marks where it sits; other placeholders
+// are dropped. It fails if a child database is nested inside another
+// block that the push would delete.
+func (c *Client) keepChildDatabases(current, blocks []Block, keep map[string]bool) ([]Block, error) {
+	onPage := make(map[string]string) // normalized ID -> ID
+	for _, b := range current {
+		if b.Type == "child_database" {
+			onPage[strings.ReplaceAll(b.ID, "-", "")] = b.ID
+			keep[b.ID] = true
+		}
+	}
+	for _, b := range current {
+		if keep[b.ID] || !mayHoldDatabase(b) {
+			continue
+		}
+		id, err := c.findNestedDatabase(b.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block %s: %w", b.ID, err)
+		}
+		if id != "" {
+			return nil, fmt.Errorf("child database %s is inside another block on the page, so pushing would delete it; move it to the top level of the page in Notion", id)
+		}
+	}
+
+	placed := make(map[string]bool)
+	var place func(blocks []Block, topLevel bool) []Block
+	place = func(blocks []Block, topLevel bool) []Block {
+		out := blocks[:0]
+		for _, b := range blocks {
+			if b.Type == "child_database" {
+				id := onPage[strings.ReplaceAll(b.ID, "-", "")]
+				if !topLevel || id == "" || placed[id] {
+					debugLog("keepChildDatabases: dropping placeholder for %s", b.ID)
+					continue
+				}
+				placed[id] = true
+				b.ID = id
+			} else {
+				b.Children = place(b.Children, false)
+			}
+			out = append(out, b)
+		}
+		return out
+	}
+	return place(blocks, true), nil
+}
+
+// mayHoldDatabase reports whether a child database could be nested inside
+// b, as part of this page.
+func mayHoldDatabase(b Block) bool {
+	switch {
+	case !b.HasChildren, b.Type == "child_page", b.Type == "table":
+		return false
+	case b.Type == "synced_block" && b.SyncedFrom != "":
+		// A reference's content belongs to the original
+		return false
+	}
+	return true
+}
+
+// findNestedDatabase returns the ID of a child database anywhere inside a
+// block, or "" if there is none.
+func (c *Client) findNestedDatabase(blockID string) (string, error) {
+	children, err := c.fetchBlockChildren(blockID)
+	if err != nil {
+		return "", err
+	}
+	for _, child := range children {
+		if child.Type == "child_database" {
+			return child.ID, nil
+		}
+		if mayHoldDatabase(child) {
+			if id, err := c.findNestedDatabase(child.ID); id != "" || err != nil {
+				return id, err
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,203 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// prefetchWorkers is how many related pages are fetched at once. Notion
+// allows about three requests per second on average, and rate-limited
+// requests are retried.
+const prefetchWorkers = 3
+
+// relatedPage is a cached page fetched while expanding relations.
+type relatedPage struct {
+	Title      string
+	Properties map[string]map[string]any
+	Err        error
+}
+
+// ExpandRelations replaces relation page IDs in flattened query results with
+// {id, title} objects.
+//
+// Related pages are fetched once each (deduplicated across all rows) and
+// cached on the client, like user names. With depth > 1, the selected
+// properties of each related page (all properties if none are given) are
+// flattened into a "properties" field; relations inside those properties are
+// expanded recursively until depth is exhausted.
+func (c *Client) ExpandRelations(databaseID string, result *QueryResult, depth int, properties []string) error {
+	if depth < 1 {
+		depth = 1
+	}
+
+	schema, err := c.GetSchema(databaseID)
+	if err != nil {
+		return fmt.Errorf("failed to get schema: %w", err)
+	}
+	var relationProps []string
+	for _, p := range schema {
+		if p.Type == "relation" {
+			relationProps = append(relationProps, p.Name)
+		}
+	}
+	if len(relationProps) == 0 {
+		return nil
+	}
+
+	// Batch: collect every related ID across all rows, then fetch each once.
+	var ids []string
+	for _, row := range result.Results {
+		for _, name := range relationProps {
+			if rel, ok := row[name].([]string); ok {
+				ids = append(ids, rel...)
+			}
+		}
+	}
+	c.prefetchPages(ids)
+
+	for _, row := range result.Results {
+		for _, name := range relationProps {
+			if rel, ok := row[name].([]string); ok {
+				row[name] = c.expandRelationIDs(rel, depth, properties)
+			}
+		}
+	}
+	return nil
+}
+
+// prefetchPages fetches all uncached pages in ids, skipping duplicates,
+// a few at a time.
+func (c *Client) prefetchPages(ids []string) {
+	seen := make(map[string]bool)
+	var missing []string
+	for _, id := range ids {
+		key := strings.ReplaceAll(id, "-", "")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := c.pageCache[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	debugLog("prefetchPages: %d related pages, %d uncached", len(seen), len(missing))
+
+	pages := make([]*relatedPage, len(missing))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(prefetchWorkers, len(missing)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pages[i] = c.loadRelatedPage(missing[i])
+			}
+		}()
+	}
+	for i := range missing {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// The cache is only written here, so the workers don't share it
+	for i, key := range missing {
+		c.pageCache[key] = pages[i]
+	}
+}
+
+// fetchRelatedPage returns a page's title and raw properties, using the cache.
+func (c *Client) fetchRelatedPage(pageID string) *relatedPage {
+	key := strings.ReplaceAll(pageID, "-", "")
+	if page, ok := c.pageCache[key]; ok {
+		return page
+	}
+	page := c.loadRelatedPage(key)
+	c.pageCache[key] = page
+	return page
+}
+
+// loadRelatedPage fetches a page's title and raw properties, without the
+// cache.
+func (c *Client) loadRelatedPage(key string) *relatedPage {
+	page := &relatedPage{}
+	url := fmt.Sprintf("%s/pages/%s", c.apiBase, key)
+	resp, err := c.doRequest("GET", url, nil)
+	if err == nil {
+		var raw struct {
+			Properties map[string]map[string]any `json:"properties"`
+		}
+		if err = json.Unmarshal(resp, &raw); err == nil {
+			page.Properties = raw.Properties
+			for _, prop := range raw.Properties {
+				if t, _ := prop["type"].(string); t == "title" {
+					page.Title = extractRichText(prop["title"])
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		debugLog("loadRelatedPage: %s: %v", key, err)
+		page.Err = err
+	}
+	return page
+}
+
+// expandRelationIDs converts related page IDs to {id, title} objects,
+// optionally with flattened properties of the related pages.
+func (c *Client) expandRelationIDs(ids []string, depth int, properties []string) []map[string]any {
+	expanded := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		page := c.fetchRelatedPage(id)
+		item := map[string]any{
+			"id":    id,
+			"title": page.Title,
+		}
+		if page.Err != nil {
+			item["error"] = "not accessible"
+		} else if depth > 1 {
+			item["properties"] = c.flattenRelatedProperties(page, depth-1, properties)
+		}
+		expanded = append(expanded, item)
+	}
+	return expanded
+}
+
+// flattenRelatedProperties flattens the selected properties of a related page.
+// Relations among them are expanded while depth remains.
+func (c *Client) flattenRelatedProperties(page *relatedPage, depth int, properties []string) map[string]any {
+	selected := make(map[string]bool)
+	for _, name := range properties {
+		selected[name] = true
+	}
+
+	flat := make(map[string]any)
+	var nestedIDs []string
+	for name, prop := range page.Properties {
+		if len(selected) > 0 && !selected[name] {
+			continue
+		}
+		flat[name] = c.flattenProperty(prop)
+		if t, _ := prop["type"].(string); t == "relation" && depth > 1 {
+			if rel, ok := flat[name].([]string); ok {
+				nestedIDs = append(nestedIDs, rel...)
+			}
+		}
+	}
+
+	if len(nestedIDs) > 0 {
+		c.prefetchPages(nestedIDs)
+		for name, prop := range page.Properties {
+			if t, _ := prop["type"].(string); t != "relation" {
+				continue
+			}
+			if rel, ok := flat[name].([]string); ok {
+				flat[name] = c.expandRelationIDs(rel, depth-1, properties)
+			}
+		}
+	}
+	return flat
+}
@@ -0,0 +1,123 @@
+package notion
+
+import (
+	"fmt"
+)
+
+// replacePageContent replaces the content of a page, whose top-level blocks
+// are current, with blocks.
+//
+// Blocks whose IDs are in keep can't be re-created without breaking
+// something, so they stay where they are: everything else is deleted, and
+// the new content is inserted around them. A block in blocks with a kept ID
+// marks where that block sits in the new content. Kept blocks that blocks
+// doesn't mention stay in place too. Without kept blocks, the page is
+// erased in one call instead.
+func (c *Client) replacePageContent(pageID string, current, blocks []Block, keep map[string]bool) error {
+	if len(keep) == 0 {
+		debugLog("replacePageContent: erasing page content")
+		if err := c.erasePage(pageID); err != nil {
+			return fmt.Errorf("failed to erase page: %w", err)
+		}
+		debugLog("replacePageContent: appending %d blocks", len(blocks))
+		if err := c.appendBlocksBatched(pageID, blocks); err != nil {
+			return fmt.Errorf("failed to append blocks: %w", err)
+		}
+		return nil
+	}
+
+	position := make(map[string]int)
+	for i, b := range current {
+		if keep[b.ID] {
+			position[b.ID] = i
+		}
+	}
+
+	// Split the content at the kept blocks. Each piece after a kept block
+	// is inserted after it.
+	var head []Block
+	var jobs []appendJob
+	last := -1
+	for _, b := range blocks {
+		if b.ID == "" || !keep[b.ID] {
+			if len(jobs) == 0 {
+				head = append(head, b)
+			} else {
+				jobs[len(jobs)-1].blocks = append(jobs[len(jobs)-1].blocks, b)
+			}
+			continue
+		}
+		i, ok := position[b.ID]
+		if !ok {
+			return fmt.Errorf("block %s is not on the page", b.ID)
+		}
+		if i < last {
+			return fmt.Errorf("block %s was moved, but it can't be re-created; keep it in the order it has in Notion", b.ID)
+		}
+		last = i
+		jobs = append(jobs, appendJob{parentID: pageID, after: b.ID})
+	}
+
+	// Content before the first kept block goes after the block before it
+	// on the page, which is deleted once the content is in place
+	anchor := ""
+	if len(head) > 0 {
+		if len(jobs) == 0 {
+			jobs = append(jobs, appendJob{parentID: pageID, blocks: head})
+		} else {
+			i := position[jobs[0].after]
+			if i == 0 {
+				return fmt.Errorf("content can't be added above block %s, the first block on the page", jobs[0].after)
+			}
+			anchor = current[i-1].ID
+			jobs = append([]appendJob{{parentID: pageID, after: anchor, blocks: head}}, jobs...)
+		}
+	}
+
+	debugLog("replacePageContent: deleting %d blocks, keeping %d", len(current)-len(keep), len(keep))
+	for _, b := range current {
+		if keep[b.ID] || b.ID == anchor {
+			continue
+		}
+		if err := c.deleteBlock(b.ID); err != nil {
+			return fmt.Errorf("failed to delete block %s: %w", b.ID, err)
+		}
+	}
+
+	for _, job := range jobs {
+		if len(job.blocks) == 0 {
+			continue
+		}
+		debugLog("replacePageContent: inserting %d blocks after %q", len(job.blocks), job.after)
+		if err := c.appendBlocksAfter(job.parentID, job.after, job.blocks); err != nil {
+			return fmt.Errorf("failed to append blocks: %w", err)
+		}
+	}
+
+	if anchor != "" && !keep[anchor] {
+		if err := c.deleteBlock(anchor); err != nil {
+			return fmt.Errorf("failed to delete block %s: %w", anchor, err)
+		}
+	}
+	return nil
+}
+
+// replaceChildren replaces the children of a block with blocks.
+func (c *Client) replaceChildren(blockID string, blocks []Block) error {
+	ids, err := c.fetchChildIDs(blockID)
+	if err != nil {
+		return fmt.Errorf("failed to list children of %s: %w", blockID, err)
+	}
+	for _, id := range ids {
+		if err := c.deleteBlock(id); err != nil {
+			return fmt.Errorf("failed to delete block %s: %w", id, err)
+		}
+	}
+	return c.appendBlocksBatched(blockID, blocks)
+}
+
+// deleteBlock moves a block to the trash.
+func (c *Client) deleteBlock(blockID string) error {
+	_, err := c.doRequest("DELETE", fmt.Sprintf("%s/blocks/%s", c.apiBase, blockID), nil)
+	return err
+}
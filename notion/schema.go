@@ -0,0 +1,363 @@
+package notion
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaFile is the on-disk representation of a database schema.
+// It is kept in version control next to synced docs so that database
+// structure changes can be reviewed like any other change.
+type SchemaFile struct {
+	DatabaseID string           `yaml:"database_id"`
+	Properties []SchemaProperty `yaml:"properties"`
+}
+
+// SchemaChange describes one difference between a schema file and the live database.
+type SchemaChange struct {
+	Action   string `json:"action"`   // add, remove, change_type, add_option, remove_option, change_option_color, change_format, change_expression, change_relation
+	Property string `json:"property"` // Property name
+	Detail   string `json:"detail,omitempty"`
+	Applied  bool   `json:"applied"`
+	Skipped  string `json:"skipped,omitempty"` // Reason the change was not applied
+}
+
+// SchemaDiffResult contains the drift between a schema file and the live database.
+type SchemaDiffResult struct {
+	DatabaseID string         `json:"database_id"`
+	Changes    []SchemaChange `json:"changes"`
+	Applied    int            `json:"applied"`
+}
+
+// ExportSchema returns the schema of a database as YAML.
+// Properties and options are sorted by name so exports are stable across runs.
+func (c *Client) ExportSchema(databaseID string) ([]byte, error) {
+	databaseID = strings.ReplaceAll(databaseID, "-", "")
+	schema, err := c.GetSchema(databaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(schema, func(i, j int) bool { return schema[i].Name < schema[j].Name })
+
+	return yaml.Marshal(SchemaFile{
+		DatabaseID: databaseID,
+		Properties: schema,
+	})
+}
+
+// LoadSchemaFile reads and parses a YAML schema file.
+func LoadSchemaFile(filePath string) (*SchemaFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var sf SchemaFile
+	if err := yaml.Unmarshal(data, &sf); err != nil {
+		return nil, fmt.Errorf("failed to parse schema file: %w", err)
+	}
+	if sf.DatabaseID == "" {
+		return nil, fmt.Errorf("no database_id found in schema file")
+	}
+	sf.DatabaseID = strings.ReplaceAll(sf.DatabaseID, "-", "")
+
+	seen := make(map[string]bool)
+	for _, p := range sf.Properties {
+		if p.Name == "" || p.Type == "" {
+			return nil, fmt.Errorf("schema file property is missing name or type")
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("duplicate property %q in schema file", p.Name)
+		}
+		seen[p.Name] = true
+	}
+
+	return &sf, nil
+}
+
+// ApplySchema compares a schema file against the live database and reports drift.
+// If apply is true, supported changes are sent to Notion in a single
+// PATCH /databases/{id} request. Removals (properties and select options)
+// are only applied when prune is true, so a partial schema file never
+// deletes data by accident.
+func (c *Client) ApplySchema(filePath string, apply, prune bool) (*SchemaDiffResult, error) {
+	sf, err := LoadSchemaFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := c.GetSchema(sf.DatabaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema: %w", err)
+	}
+
+	changes := DiffSchema(sf.Properties, live)
+	result := &SchemaDiffResult{DatabaseID: sf.DatabaseID, Changes: changes}
+	if !apply || len(changes) == 0 {
+		return result, nil
+	}
+
+	desired := make(map[string]SchemaProperty)
+	for _, p := range sf.Properties {
+		desired[p.Name] = p
+	}
+	liveByName := make(map[string]SchemaProperty)
+	for _, p := range live {
+		liveByName[p.Name] = p
+	}
+
+	props := make(map[string]any)
+	for i := range changes {
+		ch := &changes[i]
+		if reason := schemaChangeUnsupported(*ch, desired[ch.Property], liveByName[ch.Property], prune); reason != "" {
+			ch.Skipped = reason
+			continue
+		}
+		if ch.Action == "remove" {
+			props[ch.Property] = nil
+			continue
+		}
+		// All other changes are expressed by sending the full desired
+		// definition of the property, which Notion treats as an update.
+		props[ch.Property] = schemaPropertyToAPI(desired[ch.Property], liveByName[ch.Property], prune)
+	}
+
+	if len(props) == 0 {
+		return result, nil
+	}
+
+	debugLog("ApplySchema: updating %d properties on %s", len(props), sf.DatabaseID)
+	url := fmt.Sprintf("%s/databases/%s", c.apiBase, sf.DatabaseID)
+	if _, err := c.doRequest("PATCH", url, map[string]any{"properties": props}); err != nil {
+		return nil, fmt.Errorf("failed to update database: %w", err)
+	}
+
+	for i := range changes {
+		if changes[i].Skipped == "" {
+			changes[i].Applied = true
+			result.Applied++
+		}
+	}
+	return result, nil
+}
+
+// DiffSchema compares a desired schema against the live one.
+// Changes are returned in a stable order (by property name, then action).
+func DiffSchema(desired, live []SchemaProperty) []SchemaChange {
+	liveByName := make(map[string]SchemaProperty)
+	for _, p := range live {
+		liveByName[p.Name] = p
+	}
+	desiredByName := make(map[string]SchemaProperty)
+	for _, p := range desired {
+		desiredByName[p.Name] = p
+	}
+
+	var changes []SchemaChange
+	for _, want := range desired {
+		have, ok := liveByName[want.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Action: "add", Property: want.Name, Detail: want.Type})
+			continue
+		}
+		if want.Type != have.Type {
+			changes = append(changes, SchemaChange{
+				Action:   "change_type",
+				Property: want.Name,
+				Detail:   fmt.Sprintf("%s -> %s", have.Type, want.Type),
+			})
+			continue
+		}
+		changes = append(changes, diffSchemaProperty(want, have)...)
+	}
+	for _, have := range live {
+		if _, ok := desiredByName[have.Name]; !ok {
+			changes = append(changes, SchemaChange{Action: "remove", Property: have.Name, Detail: have.Type})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Property < changes[j].Property
+	})
+	return changes
+}
+
+// diffSchemaProperty compares the type-specific configuration of two
+// properties that share a name and type.
+func diffSchemaProperty(want, have SchemaProperty) []SchemaChange {
+	var changes []SchemaChange
+
+	haveOpts := make(map[string]SchemaOption)
+	for _, o := range have.Options {
+		haveOpts[o.Name] = o
+	}
+	wantOpts := make(map[string]bool)
+	for _, o := range want.Options {
+		wantOpts[o.Name] = true
+		h, ok := haveOpts[o.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Action: "add_option", Property: want.Name, Detail: o.Name})
+		} else if o.Color != "" && o.Color != h.Color {
+			changes = append(changes, SchemaChange{
+				Action:   "change_option_color",
+				Property: want.Name,
+				Detail:   fmt.Sprintf("%s: %s -> %s", o.Name, h.Color, o.Color),
+			})
+		}
+	}
+	for _, o := range have.Options {
+		if !wantOpts[o.Name] {
+			changes = append(changes, SchemaChange{Action: "remove_option", Property: want.Name, Detail: o.Name})
+		}
+	}
+
+	if want.Format != "" && want.Format != have.Format {
+		changes = append(changes, SchemaChange{
+			Action:   "change_format",
+			Property: want.Name,
+			Detail:   fmt.Sprintf("%s -> %s", have.Format, want.Format),
+		})
+	}
+	if want.Expression != "" && want.Expression != have.Expression {
+		changes = append(changes, SchemaChange{Action: "change_expression", Property: want.Name, Detail: want.Expression})
+	}
+	if want.RelationDatabase != "" && strings.ReplaceAll(want.RelationDatabase, "-", "") != have.RelationDatabase {
+		changes = append(changes, SchemaChange{
+			Action:   "change_relation",
+			Property: want.Name,
+			Detail:   fmt.Sprintf("%s -> %s", have.RelationDatabase, want.RelationDatabase),
+		})
+	} else if want.RelationKind != "" && want.RelationKind != have.RelationKind {
+		changes = append(changes, SchemaChange{
+			Action:   "change_relation",
+			Property: want.Name,
+			Detail:   fmt.Sprintf("%s -> %s", have.RelationKind, want.RelationKind),
+		})
+	}
+
+	return changes
+}
+
+// schemaCreatableTypes are the property types schemaPropertyToAPI can
+// create, because their configuration is part of the schema file or they
+// have none. Others, such as rollups, need configuration that isn't
+// exported.
+var schemaCreatableTypes = map[string]bool{
+	"title":            true,
+	"rich_text":        true,
+	"number":           true,
+	"select":           true,
+	"multi_select":     true,
+	"date":             true,
+	"people":           true,
+	"files":            true,
+	"checkbox":         true,
+	"url":              true,
+	"email":            true,
+	"phone_number":     true,
+	"formula":          true,
+	"relation":         true,
+	"created_time":     true,
+	"created_by":       true,
+	"last_edited_time": true,
+	"last_edited_by":   true,
+}
+
+// schemaChangeUnsupported returns why a change cannot be applied, or "" if it can.
+// Every applied change goes into one request, which Notion rejects as a
+// whole if any property in it is invalid.
+func schemaChangeUnsupported(ch SchemaChange, want, live SchemaProperty, prune bool) string {
+	creates := ch.Action == "add" || ch.Action == "change_type"
+	switch {
+	case (ch.Action == "remove" || ch.Action == "remove_option") && !prune:
+		return "removals require prune"
+	case live.Type == "title" && (ch.Action == "remove" || ch.Action == "change_type"):
+		return "the title property cannot be removed or retyped"
+	case live.Type == "status" && ch.Action != "remove" && ch.Action != "change_type":
+		return "status options cannot be changed through the API"
+	case ch.Action == "change_option_color":
+		return "existing option colors cannot be changed through the API"
+	case ch.Action == "add" && ch.Detail == "status":
+		return "status properties cannot be created through the API"
+	case creates && !schemaCreatableTypes[want.Type]:
+		return fmt.Sprintf("%s properties need configuration the schema file doesn't hold", want.Type)
+	case (creates || ch.Action == "change_relation") && want.Type == "relation" && want.RelationDatabase == "":
+		return "relation properties need relation_database"
+	case creates && want.Type == "formula" && want.Expression == "":
+		return "formula properties need an expression"
+	}
+	return ""
+}
+
+// schemaPropertyToAPI builds the Notion property definition for a desired property.
+// For select options, live options are kept unless prune is set, since
+// Notion removes any option that is missing from an update. Existing
+// options keep their live color, since the API can't change it and would
+// reject the whole update.
+func schemaPropertyToAPI(want, live SchemaProperty, prune bool) map[string]any {
+	config := map[string]any{}
+
+	switch want.Type {
+	case "select", "multi_select":
+		liveColors := make(map[string]string)
+		if live.Type == want.Type {
+			for _, o := range live.Options {
+				liveColors[o.Name] = o.Color
+			}
+		}
+		var opts []map[string]any
+		seen := make(map[string]bool)
+		for _, o := range want.Options {
+			opt := map[string]any{"name": o.Name}
+			color, exists := liveColors[o.Name]
+			if !exists {
+				color = o.Color
+			}
+			if color != "" {
+				opt["color"] = color
+			}
+			opts = append(opts, opt)
+			seen[o.Name] = true
+		}
+		if !prune && live.Type == want.Type {
+			for _, o := range live.Options {
+				if !seen[o.Name] {
+					opt := map[string]any{"name": o.Name}
+					if o.Color != "" {
+						opt["color"] = o.Color
+					}
+					opts = append(opts, opt)
+				}
+			}
+		}
+		if opts != nil {
+			config["options"] = opts
+		}
+	case "number":
+		if want.Format != "" {
+			config["format"] = want.Format
+		}
+	case "formula":
+		config["expression"] = want.Expression
+	case "relation":
+		// A relation keeps its kind unless the file names another, so a
+		// two-way relation doesn't become one-way
+		kind := want.RelationKind
+		if kind == "" && live.Type == "relation" {
+			kind = live.RelationKind
+		}
+		if kind == "" {
+			kind = "single_property"
+		}
+		config["database_id"] = want.RelationDatabase
+		config["type"] = kind
+		config[kind] = map[string]any{}
+	}
+
+	return map[string]any{want.Type: config}
+}
@@ -0,0 +1,126 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SearchItem is a compact search result.
+type SearchItem struct {
+	ID             string `json:"id"`
+	Object         string `json:"object"` // page or database
+	Title          string `json:"title"`
+	Parent         string `json:"parent"` // e.g. "page:<id>", "database:<id>", "workspace"
+	URL            string `json:"url"`
+	LastEditedTime string `json:"last_edited_time"`
+}
+
+// SearchResult contains one page of search results.
+type SearchResult struct {
+	Results    []SearchItem `json:"results"`
+	HasMore    bool         `json:"has_more"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// Search searches pages and databases shared with the integration by title.
+// objectType filters to "page" or "database" (empty for both). sortDirection
+// sorts by last_edited_time ("ascending" or "descending"; empty for Notion's
+// relevance order).
+func (c *Client) Search(query, objectType, sortDirection, cursor string, limit int) (*SearchResult, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+
+	body := map[string]any{
+		"page_size": limit,
+	}
+	if query != "" {
+		body["query"] = query
+	}
+	if objectType != "" {
+		if objectType != "page" && objectType != "database" {
+			return nil, fmt.Errorf("object type must be \"page\" or \"database\", got %q", objectType)
+		}
+		body["filter"] = map[string]any{
+			"property": "object",
+			"value":    objectType,
+		}
+	}
+	if sortDirection != "" {
+		if sortDirection != "ascending" && sortDirection != "descending" {
+			return nil, fmt.Errorf("sort direction must be \"ascending\" or \"descending\", got %q", sortDirection)
+		}
+		body["sort"] = map[string]any{
+			"direction": sortDirection,
+			"timestamp": "last_edited_time",
+		}
+	}
+	if cursor != "" {
+		body["start_cursor"] = cursor
+	}
+
+	url := fmt.Sprintf("%s/search", c.apiBase)
+	resp, err := c.doRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Results []struct {
+			ID             string                    `json:"id"`
+			Object         string                    `json:"object"`
+			URL            string                    `json:"url"`
+			LastEditedTime string                    `json:"last_edited_time"`
+			Parent         map[string]any            `json:"parent"`
+			Title          []any                     `json:"title"`
+			Properties     map[string]map[string]any `json:"properties"`
+		} `json:"results"`
+		HasMore    bool   `json:"has_more"`
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(resp, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse search results: %w", err)
+	}
+
+	result := &SearchResult{
+		Results:    []SearchItem{},
+		HasMore:    raw.HasMore,
+		NextCursor: raw.NextCursor,
+	}
+	for _, r := range raw.Results {
+		item := SearchItem{
+			ID:             strings.ReplaceAll(r.ID, "-", ""),
+			Object:         r.Object,
+			URL:            r.URL,
+			LastEditedTime: r.LastEditedTime,
+			Parent:         formatParent(r.Parent),
+		}
+		if r.Object == "database" {
+			item.Title = extractRichText(r.Title)
+		} else {
+			for _, prop := range r.Properties {
+				if t, _ := prop["type"].(string); t == "title" {
+					item.Title = extractRichText(prop["title"])
+					break
+				}
+			}
+		}
+		result.Results = append(result.Results, item)
+	}
+
+	return result, nil
+}
+
+// formatParent renders a Notion parent object as "type:id" (or "workspace").
+func formatParent(parent map[string]any) string {
+	parentType, _ := parent["type"].(string)
+	switch parentType {
+	case "workspace":
+		return "workspace"
+	case "page_id", "database_id", "block_id":
+		id, _ := parent[parentType].(string)
+		return strings.TrimSuffix(parentType, "_id") + ":" + strings.ReplaceAll(id, "-", "")
+	}
+	return parentType
+}
@@ -17,6 +17,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -32,18 +33,25 @@ func debugLog(format string, args ...any) {
 }
 
 const (
-	notionAPIBase    = "https://api.notion.com/v1"
+	defaultAPIBase   = "https://api.notion.com/v1"
 	notionAPIVersion = "2022-06-28"
+
+	// maxRateLimitRetries is how often a request is retried after a 429.
+	maxRateLimitRetries = 3
 )
 
 // Client handles Notion API operations with efficiency optimizations.
 type Client struct {
 	apiKey     string
+	apiBase    string // API root URL, without a trailing slash
 	httpClient *http.Client
-	userCache  map[string]string // user ID -> name cache
+	userCache  map[string]string       // user ID -> name cache
+	pageCache  map[string]*relatedPage // page ID -> title and properties cache
 }
 
 // NewClient creates a new client using NOTION_API_KEY env var.
+// NOTION_API_BASE, if set, replaces the API root URL, e.g. to run against a
+// local fake server.
 func NewClient() (*Client, error) {
 	apiKey := os.Getenv("NOTION_API_KEY")
 	if apiKey == "" {
@@ -52,12 +60,18 @@ func NewClient() (*Client, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("NOTION_API_KEY not found in environment or .env file")
 	}
+	apiBase := os.Getenv("NOTION_API_BASE")
+	if apiBase == "" {
+		apiBase = defaultAPIBase
+	}
 	return &Client{
-		apiKey: apiKey,
+		apiKey:  apiKey,
+		apiBase: strings.TrimSuffix(apiBase, "/"),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 		userCache: make(map[string]string),
+		pageCache: make(map[string]*relatedPage),
 	}, nil
 }
 
@@ -101,13 +115,14 @@ type PullResult struct {
 // PullPage fetches a Notion page with comments and saves as markdown.
 // This is the basic version without link rewriting. Use PullPageWithScope for full functionality.
 func (c *Client) PullPage(pageID string, outputDir string) (*PullResult, error) {
-	return c.PullPageWithScope(pageID, outputDir, "", true)
+	return c.PullPageWithScope(pageID, outputDir, "", true, false)
 }
 
 // PullPageWithScope fetches a Notion page and saves as markdown, with link rewriting.
 // If scope is provided, it scans for .md files with notion_id and rewrites notion:// links
 // to relative paths where local copies exist. Also updates other files in scope.
-func (c *Client) PullPageWithScope(pageID string, outputDir string, scope string, recursive bool) (*PullResult, error) {
+// Underlines, text colors and block colors are only written if lossless is set.
+func (c *Client) PullPageWithScope(pageID string, outputDir string, scope string, recursive bool, lossless bool) (*PullResult, error) {
 	pageID = strings.ReplaceAll(pageID, "-", "")
 
 	title, err := c.getPageTitle(pageID)
@@ -119,6 +134,7 @@ func (c *Client) PullPageWithScope(pageID string, outputDir string, scope string
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch blocks: %w", err)
 	}
+	c.resolveLinkTitles(blocks)
 
 	// Find child pages and determine which are trailing (after last non-child_page content)
 	var childPageIDs []string
@@ -126,12 +142,8 @@ func (c *Client) PullPageWithScope(pageID string, outputDir string, scope string
 
 	// First pass: collect all child page IDs
 	for _, block := range blocks {
-		blockType, _ := block["type"].(string)
-		if blockType == "child_page" {
-			blockID, _ := block["id"].(string)
-			if blockID != "" {
-				childPageIDs = append(childPageIDs, blockID)
-			}
+		if block.Type == "child_page" && block.ID != "" {
+			childPageIDs = append(childPageIDs, block.ID)
 		}
 	}
 
@@ -139,19 +151,14 @@ func (c *Client) PullPageWithScope(pageID string, outputDir string, scope string
 	// Trailing = any child_page after the last non-child_page block
 	lastNonChildPageIdx := -1
 	for i, block := range blocks {
-		blockType, _ := block["type"].(string)
-		if blockType != "child_page" {
+		if block.Type != "child_page" {
 			lastNonChildPageIdx = i
 		}
 	}
 
 	for i, block := range blocks {
-		blockType, _ := block["type"].(string)
-		if blockType == "child_page" && i > lastNonChildPageIdx {
-			blockID, _ := block["id"].(string)
-			if blockID != "" {
-				trailingChildPages[blockID] = true
-			}
+		if block.Type == "child_page" && i > lastNonChildPageIdx && block.ID != "" {
+			trailingChildPages[block.ID] = true
 		}
 	}
 
@@ -159,6 +166,21 @@ func (c *Client) PullPageWithScope(pageID string, outputDir string, scope string
 
 	comments, _ := c.fetchComments(pageID)
 
+	if outputDir == "" {
+		outputDir = "/tmp/notion"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	// Notion-hosted files expire, so keep copies next to the markdown
+	c.downloadAssets(blocks, outputDir)
+
+	// Underlines and colors are written as inline HTML, so only on request
+	if !lossless {
+		blocks = withoutColors(blocks)
+	}
+
 	// Convert blocks to markdown, with child pages as mentions (except trailing ones)
 	markdown := BlocksToMarkdownWithChildPages(blocks, trailingChildPages)
 
@@ -166,17 +188,10 @@ func (c *Client) PullPageWithScope(pageID string, outputDir string, scope string
 		markdown += "\n---\n\n## Comments\n\n"
 		for _, comment := range comments {
 			date := comment.CreatedAt.Format("Jan 2, 2006")
-			markdown += fmt.Sprintf("> **%s** *(%s)*: %s\n\n", comment.Author, date, comment.Content)
+			markdown += formatComment(comment.Author, date, comment.Content)
 		}
 	}
 
-	if outputDir == "" {
-		outputDir = "/tmp/notion"
-	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output dir: %w", err)
-	}
-
 	safeTitle := sanitizeFilename(title)
 	filePath := filepath.Join(outputDir, safeTitle+".md")
 
@@ -270,7 +285,7 @@ func countLinkDifferences(old, new string) int {
 // PushPage reads a markdown file and pushes to Notion.
 // This is the basic version without link rewriting. Use PushPageWithScope for full functionality.
 func (c *Client) PushPage(filePath string) error {
-	return c.PushPageWithScope(filePath, "", true)
+	return c.PushPageWithScope(filePath, "", true, false)
 }
 
 // PushPageWithScope reads a markdown file and pushes to Notion, with link rewriting.
@@ -278,7 +293,9 @@ func (c *Client) PushPage(filePath string) error {
 // to notion://UUID links before pushing.
 // Child pages tracked in frontmatter are re-parented after the push
 // so they appear at the bottom of the page.
-func (c *Client) PushPageWithScope(filePath string, scope string, recursive bool) error {
+// Synced blocks are pushed as references to their originals. Edited synced
+// content is an error unless editSynced is set, which updates the originals.
+func (c *Client) PushPageWithScope(filePath string, scope string, recursive bool, editSynced bool) error {
 	debugLog("PushPageWithScope: reading %s", filePath)
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -320,32 +337,54 @@ func (c *Client) PushPageWithScope(filePath string, scope string, recursive bool
 	debugLog("PushPage: converted to %d blocks", len(blocks))
 
 	if preservedComments != "" {
-		blocks = append(blocks, map[string]any{
-			"object":  "block",
-			"type":    "divider",
-			"divider": map[string]any{},
-		})
-		blocks = append(blocks, map[string]any{
-			"object": "block",
-			"type":   "heading_2",
-			"heading_2": map[string]any{
-				"rich_text": []map[string]any{
-					{"type": "text", "text": map[string]string{"content": "Comments"}},
-				},
-			},
-		})
+		blocks = append(blocks, Block{Type: "divider"})
+		blocks = append(blocks, newTextBlock("heading_2", plainRichText("Comments")))
 		blocks = append(blocks, MarkdownToBlocks(preservedComments)...)
 	}
 
-	// Simple approach: erase + replace + reparent
-	debugLog("PushPage: erasing page content")
-	if err := c.erasePage(pageID); err != nil {
-		return fmt.Errorf("failed to erase page: %w", err)
+	bookmarkLinkPreviews(blocks)
+
+	current, err := c.fetchBlockChildren(pageID)
+	if err != nil {
+		return fmt.Errorf("failed to list page content: %w", err)
+	}
+	keep, syncedUpdates, err := c.resolveSyncedBlocks(pageID, current, blocks, editSynced)
+	if err != nil {
+		return err
+	}
+	if blocks, err = c.keepChildDatabases(current, blocks, keep); err != nil {
+		return err
+	}
+
+	// Upload files and make sure the content can be sent before erasing
+	// anything
+	dir := filepath.Dir(filePath)
+	if err := c.uploadAssets(blocks, dir); err != nil {
+		return fmt.Errorf("failed to upload files: %w", err)
+	}
+	if err := checkAppendable(blocks); err != nil {
+		return fmt.Errorf("page content cannot be pushed: %w", err)
+	}
+	for _, update := range syncedUpdates {
+		if err := c.uploadAssets(update.blocks, dir); err != nil {
+			return fmt.Errorf("failed to upload files: %w", err)
+		}
+		if err := checkAppendable(update.blocks); err != nil {
+			return fmt.Errorf("synced block %s cannot be pushed: %w", update.id, err)
+		}
 	}
 
-	debugLog("PushPage: appending %d blocks", len(blocks))
-	if err := c.appendBlocksBatched(pageID, blocks); err != nil {
-		return fmt.Errorf("failed to append blocks: %w", err)
+	// Simple approach: erase + replace + reparent. Blocks that can't be
+	// re-created stay in place.
+	if err := c.replacePageContent(pageID, current, blocks, keep); err != nil {
+		return err
+	}
+
+	for _, update := range syncedUpdates {
+		debugLog("PushPage: updating synced block %s", update.id)
+		if err := c.replaceChildren(update.id, update.blocks); err != nil {
+			return fmt.Errorf("failed to update synced block %s: %w", update.id, err)
+		}
 	}
 
 	// Re-parent child pages to restore them at the bottom
@@ -376,6 +415,17 @@ func (c *Client) DiffPage(filePath string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch blocks: %w", err)
 	}
+	c.resolveLinkTitles(blocks)
+
+	// Files are compared by the local copies a pull saved, since the URLs
+	// Notion serves expire
+	localBlocks := MarkdownToBlocks(localMarkdown)
+	useLocalAssets(blocks, localAssetPaths(localBlocks))
+
+	// Compare colors only if the file has them, i.e. was pulled in lossless mode
+	if !hasColors(localBlocks) {
+		blocks = withoutColors(blocks)
+	}
 
 	notionMarkdown := BlocksToMarkdown(blocks)
 
@@ -419,12 +469,12 @@ func (c *Client) DiffPage(filePath string) (string, error) {
 }
 
 // fetchAllBlocks recursively fetches all blocks including comments.
-func (c *Client) fetchAllBlocks(blockID string) ([]map[string]any, error) {
-	var allBlocks []map[string]any
+func (c *Client) fetchAllBlocks(blockID string) ([]Block, error) {
+	var allBlocks []Block
 	cursor := ""
 
 	for {
-		url := fmt.Sprintf("%s/blocks/%s/children?page_size=100", notionAPIBase, blockID)
+		url := fmt.Sprintf("%s/blocks/%s/children?page_size=100", c.apiBase, blockID)
 		if cursor != "" {
 			url += "&start_cursor=" + cursor
 		}
@@ -435,30 +485,27 @@ func (c *Client) fetchAllBlocks(blockID string) ([]map[string]any, error) {
 		}
 
 		var result struct {
-			Results    []map[string]any `json:"results"`
-			HasMore    bool             `json:"has_more"`
-			NextCursor string           `json:"next_cursor"`
+			Results    []Block `json:"results"`
+			HasMore    bool    `json:"has_more"`
+			NextCursor string  `json:"next_cursor"`
 		}
 		if err := json.Unmarshal(resp, &result); err != nil {
 			return nil, fmt.Errorf("failed to parse response: %w", err)
 		}
 
 		for i, block := range result.Results {
-			blockID, _ := block["id"].(string)
-			if blockID == "" {
+			if block.ID == "" {
 				continue
 			}
 
-			hasChildren, _ := block["has_children"].(bool)
-			if hasChildren {
-				children, err := c.fetchBlockChildren(blockID)
-				if err == nil && len(children) > 0 {
-					blockType, _ := block["type"].(string)
-					if blockData, ok := block[blockType].(map[string]any); ok {
-						blockData["children"] = children
-						result.Results[i][blockType] = blockData
-					}
+			// Child pages and databases have their own content, which isn't
+			// part of this page
+			if block.HasChildren && block.Type != "child_page" && block.Type != "child_database" {
+				children, err := c.fetchAllBlocks(block.ID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to fetch children of %s: %w", block.ID, err)
 				}
+				result.Results[i].Children = children
 			}
 		}
 
@@ -474,12 +521,12 @@ func (c *Client) fetchAllBlocks(blockID string) ([]map[string]any, error) {
 }
 
 // fetchBlockChildren fetches immediate children of a block.
-func (c *Client) fetchBlockChildren(blockID string) ([]any, error) {
-	var allChildren []any
+func (c *Client) fetchBlockChildren(blockID string) ([]Block, error) {
+	var allChildren []Block
 	cursor := ""
 
 	for {
-		url := fmt.Sprintf("%s/blocks/%s/children?page_size=100", notionAPIBase, blockID)
+		url := fmt.Sprintf("%s/blocks/%s/children?page_size=100", c.apiBase, blockID)
 		if cursor != "" {
 			url += "&start_cursor=" + cursor
 		}
@@ -490,17 +537,15 @@ func (c *Client) fetchBlockChildren(blockID string) ([]any, error) {
 		}
 
 		var result struct {
-			Results    []map[string]any `json:"results"`
-			HasMore    bool             `json:"has_more"`
-			NextCursor string           `json:"next_cursor"`
+			Results    []Block `json:"results"`
+			HasMore    bool    `json:"has_more"`
+			NextCursor string  `json:"next_cursor"`
 		}
 		if err := json.Unmarshal(resp, &result); err != nil {
 			return nil, err
 		}
 
-		for _, r := range result.Results {
-			allChildren = append(allChildren, r)
-		}
+		allChildren = append(allChildren, result.Results...)
 
 		if !result.HasMore {
 			break
@@ -513,7 +558,7 @@ func (c *Client) fetchBlockChildren(blockID string) ([]any, error) {
 
 // fetchComments fetches all comments for a page or block.
 func (c *Client) fetchComments(blockID string) ([]Comment, error) {
-	url := fmt.Sprintf("%s/comments?block_id=%s&page_size=100", notionAPIBase, blockID)
+	url := fmt.Sprintf("%s/comments?block_id=%s&page_size=100", c.apiBase, blockID)
 
 	resp, err := c.doRequest("GET", url, nil)
 	if err != nil {
@@ -571,7 +616,7 @@ func (c *Client) resolveUserName(userID string) string {
 		return name
 	}
 
-	url := fmt.Sprintf("%s/users/%s", notionAPIBase, userID)
+	url := fmt.Sprintf("%s/users/%s", c.apiBase, userID)
 	resp, err := c.doRequest("GET", url, nil)
 	if err != nil {
 		c.userCache[userID] = "Unknown"
@@ -596,7 +641,7 @@ func (c *Client) resolveUserName(userID string) string {
 
 // getPageTitle fetches the title of a page.
 func (c *Client) getPageTitle(pageID string) (string, error) {
-	url := fmt.Sprintf("%s/pages/%s", notionAPIBase, pageID)
+	url := fmt.Sprintf("%s/pages/%s", c.apiBase, pageID)
 
 	resp, err := c.doRequest("GET", url, nil)
 	if err != nil {
@@ -624,9 +669,11 @@ func (c *Client) getPageTitle(pageID string) (string, error) {
 }
 
 // erasePage clears all content using PATCH with erase_content=true.
-// This is MUCH faster than deleting blocks one by one.
+// This is MUCH faster than deleting blocks one by one, but it takes child
+// databases with it, so replacePageContent only uses it when the page has
+// nothing to keep.
 func (c *Client) erasePage(pageID string) error {
-	url := fmt.Sprintf("%s/pages/%s", notionAPIBase, pageID)
+	url := fmt.Sprintf("%s/pages/%s", c.apiBase, pageID)
 	body := map[string]any{
 		"erase_content": true,
 	}
@@ -641,7 +688,7 @@ func (c *Client) getChildPageIDs(pageID string) ([]string, error) {
 	cursor := ""
 
 	for {
-		url := fmt.Sprintf("%s/blocks/%s/children?page_size=100", notionAPIBase, pageID)
+		url := fmt.Sprintf("%s/blocks/%s/children?page_size=100", c.apiBase, pageID)
 		if cursor != "" {
 			url += "&start_cursor=" + cursor
 		}
@@ -652,26 +699,19 @@ func (c *Client) getChildPageIDs(pageID string) ([]string, error) {
 		}
 
 		var result struct {
-			Results    []map[string]any `json:"results"`
-			HasMore    bool             `json:"has_more"`
-			NextCursor string           `json:"next_cursor"`
+			Results    []Block `json:"results"`
+			HasMore    bool    `json:"has_more"`
+			NextCursor string  `json:"next_cursor"`
 		}
 		if err := json.Unmarshal(resp, &result); err != nil {
 			return nil, fmt.Errorf("failed to parse response: %w", err)
 		}
 
 		for _, block := range result.Results {
-			blockType, _ := block["type"].(string)
-			if blockType == "child_page" {
-				if childPage, ok := block["child_page"].(map[string]any); ok {
-					if title, ok := childPage["title"].(string); ok {
-						// The block ID is the child page ID for child_page blocks
-						if blockID, ok := block["id"].(string); ok {
-							debugLog("getChildPageIDs: found child page %q with ID %s", title, blockID)
-							childPageIDs = append(childPageIDs, blockID)
-						}
-					}
-				}
+			// The block ID is the child page ID for child_page blocks
+			if block.Type == "child_page" && block.ID != "" {
+				debugLog("getChildPageIDs: found child page %q with ID %s", block.Title, block.ID)
+				childPageIDs = append(childPageIDs, block.ID)
 			}
 		}
 
@@ -688,7 +728,7 @@ func (c *Client) getChildPageIDs(pageID string) ([]string, error) {
 func (c *Client) restorePages(pageIDs []string) error {
 	for _, pageID := range pageIDs {
 		debugLog("restorePages: restoring page %s from trash", pageID)
-		url := fmt.Sprintf("%s/pages/%s", notionAPIBase, pageID)
+		url := fmt.Sprintf("%s/pages/%s", c.apiBase, pageID)
 		body := map[string]any{
 			"archived": false,
 		}
@@ -704,7 +744,7 @@ func (c *Client) restorePages(pageIDs []string) error {
 func (c *Client) reparentPages(parentPageID string, childPageIDs []string) error {
 	for _, childID := range childPageIDs {
 		debugLog("reparentPages: moving page %s under parent %s", childID, parentPageID)
-		url := fmt.Sprintf("%s/pages/%s", notionAPIBase, childID)
+		url := fmt.Sprintf("%s/pages/%s", c.apiBase, childID)
 		body := map[string]any{
 			"parent": map[string]any{
 				"page_id": parentPageID,
@@ -718,82 +758,71 @@ func (c *Client) reparentPages(parentPageID string, childPageIDs []string) error
 	return nil
 }
 
-// appendBlocksBatched appends blocks in batches of 100.
-func (c *Client) appendBlocksBatched(pageID string, blocks []map[string]any) error {
-	const batchSize = 100
-	totalBatches := (len(blocks) + batchSize - 1) / batchSize
-
-	for i := 0; i < len(blocks); i += batchSize {
-		end := i + batchSize
-		if end > len(blocks) {
-			end = len(blocks)
-		}
-		batchNum := i/batchSize + 1
-
-		batch := blocks[i:end]
-		body := map[string]any{
-			"children": batch,
-		}
-
-		debugLog("appendBlocksBatched: sending batch %d/%d (%d blocks)", batchNum, totalBatches, len(batch))
-		url := fmt.Sprintf("%s/blocks/%s/children", notionAPIBase, pageID)
-		if _, err := c.doRequest("PATCH", url, body); err != nil {
-			return fmt.Errorf("failed to append batch %d: %w", i/batchSize, err)
-		}
-		debugLog("appendBlocksBatched: batch %d complete", batchNum)
-
-		if end < len(blocks) {
-			time.Sleep(100 * time.Millisecond)
-		}
-	}
-
-	return nil
-}
-
 // doRequest makes an authenticated request to Notion API.
 func (c *Client) doRequest(method, url string, body any) ([]byte, error) {
-	var bodyReader io.Reader
-	var bodyLen int
+	var data []byte
 	if body != nil {
-		data, err := json.Marshal(body)
+		var err error
+		data, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal body: %w", err)
 		}
-		bodyLen = len(data)
-		bodyReader = bytes.NewReader(data)
 	}
+	return c.doRawRequest(method, url, "application/json", data)
+}
 
-	debugLog("doRequest: %s %s (body: %d bytes)", method, url, bodyLen)
-	start := time.Now()
+// doRawRequest makes an authenticated request with a body that is already
+// encoded, such as a multipart file upload. Rate-limited requests are
+// retried.
+func (c *Client) doRawRequest(method, url, contentType string, data []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		debugLog("doRequest: %s %s (body: %d bytes)", method, url, len(data))
+		start := time.Now()
 
-	req, err := http.NewRequest(method, url, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+		var bodyReader io.Reader
+		if data != nil {
+			bodyReader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequest(method, url, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Notion-Version", notionAPIVersion)
-	req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		req.Header.Set("Notion-Version", notionAPIVersion)
+		req.Header.Set("Content-Type", contentType)
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		debugLog("doRequest: failed after %v: %v", time.Since(start), err)
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			debugLog("doRequest: failed after %v: %v", time.Since(start), err)
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
 
-	debugLog("doRequest: %d (%d bytes) in %v", resp.StatusCode, len(respBody), time.Since(start))
+		debugLog("doRequest: %d (%d bytes) in %v", resp.StatusCode, len(respBody), time.Since(start))
 
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
-	}
+		// Rate limited: wait as told and try again
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			wait := time.Second
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+				wait = time.Duration(secs) * time.Second
+			}
+			debugLog("doRequest: rate limited, retrying in %v", wait)
+			time.Sleep(wait)
+			continue
+		}
+
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		}
 
-	return respBody, nil
+		return respBody, nil
+	}
 }
 
 // Helper functions
@@ -861,9 +890,22 @@ func extractCommentsSection(markdown string) (content, comments string) {
 }
 
 // SchemaProperty describes a database property.
+// Options, Format, Expression and RelationDatabase are only set for the
+// property types they apply to.
 type SchemaProperty struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Name             string         `json:"name" yaml:"name"`
+	Type             string         `json:"type" yaml:"type"`
+	Options          []SchemaOption `json:"options,omitempty" yaml:"options,omitempty"`
+	Format           string         `json:"format,omitempty" yaml:"format,omitempty"`
+	Expression       string         `json:"expression,omitempty" yaml:"expression,omitempty"`
+	RelationDatabase string         `json:"relation_database,omitempty" yaml:"relation_database,omitempty"`
+	RelationKind     string         `json:"relation_kind,omitempty" yaml:"relation_kind,omitempty"` // single_property or dual_property
+}
+
+// SchemaOption is a select, multi_select or status option.
+type SchemaOption struct {
+	Name  string `json:"name" yaml:"name"`
+	Color string `json:"color,omitempty" yaml:"color,omitempty"`
 }
 
 // QueryResult contains flattened database query results.
@@ -873,10 +915,11 @@ type QueryResult struct {
 	NextCursor string           `json:"next_cursor,omitempty"`
 }
 
-// GetSchema returns the schema of a database (property names and types).
+// GetSchema returns the schema of a database (property names, types and
+// type-specific configuration such as select options).
 func (c *Client) GetSchema(databaseID string) ([]SchemaProperty, error) {
 	databaseID = strings.ReplaceAll(databaseID, "-", "")
-	url := fmt.Sprintf("%s/databases/%s", notionAPIBase, databaseID)
+	url := fmt.Sprintf("%s/databases/%s", c.apiBase, databaseID)
 
 	resp, err := c.doRequest("GET", url, nil)
 	if err != nil {
@@ -884,9 +927,7 @@ func (c *Client) GetSchema(databaseID string) ([]SchemaProperty, error) {
 	}
 
 	var result struct {
-		Properties map[string]struct {
-			Type string `json:"type"`
-		} `json:"properties"`
+		Properties map[string]map[string]any `json:"properties"`
 	}
 	if err := json.Unmarshal(resp, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse schema: %w", err)
@@ -894,17 +935,78 @@ func (c *Client) GetSchema(databaseID string) ([]SchemaProperty, error) {
 
 	var schema []SchemaProperty
 	for name, prop := range result.Properties {
-		schema = append(schema, SchemaProperty{
-			Name: name,
-			Type: prop.Type,
-		})
+		schema = append(schema, schemaPropertyFromAPI(name, prop))
 	}
 
 	return schema, nil
 }
 
+// schemaPropertyFromAPI converts a raw database property definition.
+func schemaPropertyFromAPI(name string, prop map[string]any) SchemaProperty {
+	propType, _ := prop["type"].(string)
+	sp := SchemaProperty{Name: name, Type: propType}
+
+	config, _ := prop[propType].(map[string]any)
+	if config == nil {
+		return sp
+	}
+
+	switch propType {
+	case "select", "multi_select", "status":
+		if opts, ok := config["options"].([]any); ok {
+			for _, o := range opts {
+				if m, ok := o.(map[string]any); ok {
+					optName, _ := m["name"].(string)
+					color, _ := m["color"].(string)
+					sp.Options = append(sp.Options, SchemaOption{Name: optName, Color: color})
+				}
+			}
+		}
+	case "number":
+		sp.Format, _ = config["format"].(string)
+	case "formula":
+		sp.Expression, _ = config["expression"].(string)
+	case "relation":
+		id, _ := config["database_id"].(string)
+		sp.RelationDatabase = strings.ReplaceAll(id, "-", "")
+		sp.RelationKind, _ = config["type"].(string)
+	}
+
+	return sp
+}
+
 // QueryDatabase queries a database and returns flattened results.
 func (c *Client) QueryDatabase(databaseID string, filter map[string]any, sorts []map[string]any, limit int) (*QueryResult, error) {
+	return c.queryDatabasePage(databaseID, filter, sorts, limit, "")
+}
+
+// QueryAllPages pages through a database query and returns up to maxRows
+// flattened results (all rows if maxRows <= 0). truncated reports whether
+// more rows matched than were returned.
+func (c *Client) QueryAllPages(databaseID string, filter map[string]any, sorts []map[string]any, maxRows int) (rows []map[string]any, truncated bool, err error) {
+	var all []map[string]any
+	cursor := ""
+	for {
+		page, err := c.queryDatabasePage(databaseID, filter, sorts, 100, cursor)
+		if err != nil {
+			return nil, false, err
+		}
+		all = append(all, page.Results...)
+		debugLog("QueryAllPages: fetched %d rows", len(all))
+
+		more := page.HasMore && page.NextCursor != ""
+		if maxRows > 0 && len(all) >= maxRows {
+			return all[:maxRows], more || len(all) > maxRows, nil
+		}
+		if !more {
+			return all, false, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// queryDatabasePage fetches one page of query results starting at cursor.
+func (c *Client) queryDatabasePage(databaseID string, filter map[string]any, sorts []map[string]any, limit int, cursor string) (*QueryResult, error) {
 	databaseID = strings.ReplaceAll(databaseID, "-", "")
 
 	if limit <= 0 || limit > 100 {
@@ -920,8 +1022,11 @@ func (c *Client) QueryDatabase(databaseID string, filter map[string]any, sorts [
 	if sorts != nil {
 		body["sorts"] = sorts
 	}
+	if cursor != "" {
+		body["start_cursor"] = cursor
+	}
 
-	url := fmt.Sprintf("%s/databases/%s/query", notionAPIBase, databaseID)
+	url := fmt.Sprintf("%s/databases/%s/query", c.apiBase, databaseID)
 	resp, err := c.doRequest("POST", url, body)
 	if err != nil {
 		return nil, err
@@ -992,15 +1097,7 @@ func (c *Client) flattenProperty(prop map[string]any) any {
 		}
 		return nil
 	case "date":
-		if date, ok := prop["date"].(map[string]any); ok {
-			start, _ := date["start"].(string)
-			end, _ := date["end"].(string)
-			if end != "" {
-				return map[string]string{"start": start, "end": end}
-			}
-			return start
-		}
-		return nil
+		return flattenDate(prop["date"])
 	case "people":
 		if arr, ok := prop["people"].([]any); ok {
 			var names []string
@@ -1051,6 +1148,9 @@ func (c *Client) flattenProperty(prop map[string]any) any {
 	case "formula":
 		if formula, ok := prop["formula"].(map[string]any); ok {
 			ftype, _ := formula["type"].(string)
+			if ftype == "date" {
+				return flattenDate(formula["date"])
+			}
 			return formula[ftype]
 		}
 		return nil
@@ -1070,9 +1170,53 @@ func (c *Client) flattenProperty(prop map[string]any) any {
 	case "rollup":
 		if rollup, ok := prop["rollup"].(map[string]any); ok {
 			rtype, _ := rollup["type"].(string)
+			switch rtype {
+			case "array":
+				// Each item is a property value object of the rolled-up property.
+				arr, _ := rollup["array"].([]any)
+				values := []any{}
+				for _, item := range arr {
+					m, ok := item.(map[string]any)
+					if !ok {
+						continue
+					}
+					v := c.flattenProperty(m)
+					if v == nil {
+						continue
+					}
+					// Flatten one level so a rollup of multi_select or
+					// relation values is a single list.
+					if list, ok := v.([]string); ok {
+						for _, s := range list {
+							values = append(values, s)
+						}
+					} else {
+						values = append(values, v)
+					}
+				}
+				return values
+			case "date":
+				return flattenDate(rollup["date"])
+			}
 			return rollup[rtype]
 		}
 		return nil
+	case "unique_id":
+		if uid, ok := prop["unique_id"].(map[string]any); ok {
+			num := uid["number"]
+			if prefix, ok := uid["prefix"].(string); ok && prefix != "" {
+				if n, ok := num.(float64); ok {
+					return fmt.Sprintf("%s-%d", prefix, int64(n))
+				}
+			}
+			return num
+		}
+		return nil
+	case "verification":
+		if v, ok := prop["verification"].(map[string]any); ok {
+			return v["state"]
+		}
+		return nil
 	case "files":
 		if arr, ok := prop["files"].([]any); ok {
 			var urls []string
@@ -1091,8 +1235,46 @@ func (c *Client) flattenProperty(prop map[string]any) any {
 		}
 		return nil
 	default:
+		// Unknown or valueless types (e.g. button): return the raw value if
+		// there is one, otherwise an object naming the type, so the property
+		// is not lost but can't be mistaken for a real value.
+		if v, ok := prop[propType]; ok && !isEmptyValue(v) {
+			return v
+		}
+		if propType == "" {
+			return nil
+		}
+		return map[string]any{"type": propType}
+	}
+}
+
+// flattenDate normalizes a Notion date object to its start date, or to
+// {start, end} for date ranges. Used for date properties and for dates
+// returned by formulas and rollups.
+func flattenDate(v any) any {
+	date, ok := v.(map[string]any)
+	if !ok {
 		return nil
 	}
+	start, _ := date["start"].(string)
+	end, _ := date["end"].(string)
+	if end != "" {
+		return map[string]string{"start": start, "end": end}
+	}
+	return start
+}
+
+// isEmptyValue reports whether a raw property value carries no data.
+func isEmptyValue(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		return len(val) == 0
+	case []any:
+		return len(val) == 0
+	}
+	return false
 }
 
 // extractRichText extracts plain text from rich_text array.
@@ -1117,23 +1299,10 @@ func extractRichText(v any) string {
 func ScanForNotionIDs(dir string, recursive bool) (map[string]string, error) {
 	result := make(map[string]string)
 
-	walkFn := func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
-		if info.IsDir() {
-			if !recursive && path != dir {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if !strings.HasSuffix(strings.ToLower(path), ".md") {
-			return nil
-		}
-
+	err := walkMarkdownFiles(dir, recursive, func(path string, info os.FileInfo) {
 		content, err := os.ReadFile(path)
 		if err != nil {
-			return nil // Skip unreadable files
+			return // Skip unreadable files
 		}
 
 		pageID, _ := parseFrontmatter(string(content))
@@ -1142,15 +1311,33 @@ func ScanForNotionIDs(dir string, recursive bool) (map[string]string, error) {
 			pageID = strings.ReplaceAll(pageID, "-", "")
 			result[pageID] = path
 		}
-		return nil
-	}
-
-	if err := filepath.Walk(dir, walkFn); err != nil {
+	})
+	if err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
+// walkMarkdownFiles calls fn for every .md file in dir, descending into
+// subdirectories only if recursive is set. Unreadable entries are skipped.
+func walkMarkdownFiles(dir string, recursive bool, fn func(path string, info os.FileInfo)) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+		if info.IsDir() {
+			if !recursive && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(strings.ToLower(path), ".md") {
+			fn(path, info)
+		}
+		return nil
+	})
+}
+
 // RewriteNotionLinksToRelative converts notion://UUID links to relative paths.
 // The idToPath map contains normalized (no dashes) page IDs to local file paths.
 // relativeTo is the file being rewritten (used to compute relative paths).
@@ -0,0 +1,241 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Synced blocks are written between HTML comments. The opening comment
+// carries the ID of the original synced block, so a copy of the content
+// pushes back as a reference to it rather than as a static copy:
+//
+//	<!-- synced_block: 1f2e3d4c-... -->
+//
+//	Shared content
+//
+//	<!-- /synced_block -->
+//
+// An opening comment without an ID creates a new original synced block.
+
+const (
+	syncedOpenPrefix = "<!-- synced_block"
+	syncedClose      = "<!-- /synced_block -->"
+)
+
+// syncedBlockMarkdown writes a synced block with the content it shows.
+func syncedBlockMarkdown(b Block, trailingChildPages map[string]bool) string {
+	id := b.SyncedFrom
+	if id == "" {
+		id = b.ID
+	}
+	var md strings.Builder
+	if id != "" {
+		md.WriteString(syncedOpenPrefix + ": " + id + " -->\n\n")
+	} else {
+		md.WriteString(syncedOpenPrefix + " -->\n\n")
+	}
+	if content := strings.TrimRight(BlocksToMarkdownWithChildPages(b.Children, trailingChildPages), "\n"); content != "" {
+		md.WriteString(content + "\n\n")
+	}
+	md.WriteString(syncedClose + "\n\n")
+	return md.String()
+}
+
+// syncedBlockAt reads the opening comment of a synced block from nodes[i].
+// The block's SyncedFrom holds the ID from the comment.
+func syncedBlockAt(nodes []ast.Node, i int, source []byte) (Block, bool) {
+	html, ok := nodes[i].(*ast.HTMLBlock)
+	if !ok {
+		return Block{}, false
+	}
+	raw := strings.TrimSpace(htmlBlockText(html, source))
+	rest, ok := strings.CutPrefix(raw, syncedOpenPrefix)
+	if !ok {
+		return Block{}, false
+	}
+	rest, ok = strings.CutSuffix(rest, "-->")
+	if !ok {
+		return Block{}, false
+	}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return Block{Type: "synced_block"}, true
+	}
+	id, ok := strings.CutPrefix(rest, ":")
+	if id = strings.TrimSpace(id); !ok || id == "" || strings.ContainsAny(id, " \t\n") {
+		return Block{}, false
+	}
+	return Block{Type: "synced_block", SyncedFrom: id}, true
+}
+
+// syncedEnd returns the index of the comment that closes a synced block
+// whose content starts at nodes[from], or len(nodes) if it is never closed.
+func syncedEnd(nodes []ast.Node, from int, source []byte) int {
+	for i := from; i < len(nodes); i++ {
+		if html, ok := nodes[i].(*ast.HTMLBlock); ok && strings.TrimSpace(htmlBlockText(html, source)) == syncedClose {
+			return i
+		}
+	}
+	return len(nodes)
+}
+
+// syncedUpdate is new content for an original synced block.
+type syncedUpdate struct {
+	id     string
+	blocks []Block
+}
+
+// syncedOriginal is what a push knows about an original synced block.
+type syncedOriginal struct {
+	blocks []Block // the original's current content
+	edited string  // edited content, as compared markdown
+}
+
+// resolveSyncedBlocks prepares the synced blocks in blocks for a push to
+// pageID, whose top-level blocks are current.
+//
+// Every synced block with an ID becomes a reference to its original, with
+// no content of its own. An original at the top level of this page can't
+// be re-created without breaking its references elsewhere, so it is kept in
+// place: its first top-level occurrence in blocks marks where it sits, and
+// the returned set holds the IDs of kept originals. Content that differs
+// from the original's is an error unless editSynced is set, in which case
+// it is returned as an update to the original.
+func (c *Client) resolveSyncedBlocks(pageID string, current, blocks []Block, editSynced bool) (map[string]bool, []syncedUpdate, error) {
+	onPage := make(map[string]string) // normalized ID -> ID of top-level originals
+	for _, b := range current {
+		if b.Type == "synced_block" && b.SyncedFrom == "" {
+			onPage[strings.ReplaceAll(b.ID, "-", "")] = b.ID
+		}
+	}
+
+	keep := make(map[string]bool)
+	placed := make(map[string]bool)
+	originals := make(map[string]*syncedOriginal)
+	var updates []syncedUpdate
+
+	var resolve func(blocks []Block, topLevel bool) error
+	resolve = func(blocks []Block, topLevel bool) error {
+		for i := range blocks {
+			b := &blocks[i]
+			if b.Type != "synced_block" || b.SyncedFrom == "" {
+				if err := resolve(b.Children, false); err != nil {
+					return err
+				}
+				continue
+			}
+
+			key := strings.ReplaceAll(b.SyncedFrom, "-", "")
+			original, ok := originals[key]
+			if !ok {
+				var err error
+				if original, err = c.readSyncedOriginal(pageID, b.SyncedFrom, onPage[key] != ""); err != nil {
+					return err
+				}
+				originals[key] = original
+			}
+
+			// Colors only count when the file has them, which it doesn't
+			// unless it was pulled in lossless mode
+			lossless := hasColors(b.Children)
+			if edited := syncedContentMarkdown(b.Children, lossless); edited != syncedContentMarkdown(original.blocks, lossless) {
+				if !editSynced {
+					return fmt.Errorf("synced block %s was edited; push with edit_synced to update the original everywhere it appears, or undo the edit", b.SyncedFrom)
+				}
+				if original.edited != "" && original.edited != edited {
+					return fmt.Errorf("synced block %s was edited differently in two places", b.SyncedFrom)
+				}
+				if original.edited == "" {
+					original.edited = edited
+					updates = append(updates, syncedUpdate{id: b.SyncedFrom, blocks: b.Children})
+				}
+			}
+
+			if id := onPage[key]; id != "" {
+				// The original stays even if it is only referenced from
+				// inside other blocks
+				keep[id] = true
+				if topLevel && !placed[id] {
+					placed[id] = true
+					b.ID = id
+				}
+			}
+			b.Children = nil
+		}
+		return nil
+	}
+	if err := resolve(blocks, true); err != nil {
+		return nil, nil, err
+	}
+	return keep, updates, nil
+}
+
+// readSyncedOriginal reads the content of an original synced block. Unless
+// it is known to be at the top level of pageID, it must not be inside
+// other blocks of that page, which the push deletes.
+func (c *Client) readSyncedOriginal(pageID, id string, topLevel bool) (*syncedOriginal, error) {
+	if !topLevel {
+		page, nested, err := c.blockPage(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read synced block %s: %w", id, err)
+		}
+		if nested && strings.ReplaceAll(page, "-", "") == strings.ReplaceAll(pageID, "-", "") {
+			return nil, fmt.Errorf("synced block %s is inside another block on this page, so pushing would delete it; move it to the top level of the page in Notion", id)
+		}
+	}
+	children, err := c.fetchAllBlocks(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read synced block %s: %w", id, err)
+	}
+	return &syncedOriginal{blocks: children}, nil
+}
+
+// blockPage returns the ID of the page a block is on, and whether it is
+// nested inside other blocks rather than at the top level of the page.
+func (c *Client) blockPage(blockID string) (pageID string, nested bool, err error) {
+	for depth := 0; ; depth++ {
+		resp, err := c.doRequest("GET", fmt.Sprintf("%s/blocks/%s", c.apiBase, blockID), nil)
+		if err != nil {
+			return "", false, err
+		}
+		var block struct {
+			Parent struct {
+				Type    string `json:"type"`
+				PageID  string `json:"page_id"`
+				BlockID string `json:"block_id"`
+			} `json:"parent"`
+		}
+		if err := json.Unmarshal(resp, &block); err != nil {
+			return "", false, fmt.Errorf("failed to parse block: %w", err)
+		}
+		if block.Parent.Type != "block_id" {
+			return block.Parent.PageID, depth > 0, nil
+		}
+		blockID = block.Parent.BlockID
+	}
+}
+
+// syncedContentMarkdown renders synced content for comparison. Where files
+// are stored is left out, since a pulled file's local path never matches
+// the URL Notion serves for it, and so are colors unless lossless is set.
+func syncedContentMarkdown(blocks []Block, lossless bool) string {
+	if !lossless {
+		blocks = withoutColors(blocks)
+	}
+	var withoutFiles func(blocks []Block) []Block
+	withoutFiles = func(blocks []Block) []Block {
+		out := make([]Block, len(blocks))
+		for i, b := range blocks {
+			if mediaBlockTypes[b.Type] {
+				b.File, b.AssetPath = nil, "file"
+			}
+			b.Children = withoutFiles(b.Children)
+			out[i] = b
+		}
+		return out
+	}
+	return strings.TrimSpace(BlocksToMarkdown(withoutFiles(blocks)))
+}
@@ -0,0 +1,196 @@
+package notion
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// maxSinglePartUpload is the largest file the file upload API accepts in
+// one request.
+const maxSinglePartUpload = 20 * 1000 * 1000
+
+// uploadCacheFile records which local files have been uploaded, in the
+// directory of the markdown files that use them.
+const uploadCacheFile = ".notion-uploads.json"
+
+// uploadCache maps the SHA-256 of a file's content to its file upload ID,
+// so unchanged files aren't uploaded again on every push.
+type uploadCache struct {
+	path    string
+	Uploads map[string]string `json:"uploads"`
+}
+
+// loadUploadCache reads the upload cache in dir. A missing or unreadable
+// cache is empty.
+func loadUploadCache(dir string) *uploadCache {
+	cache := &uploadCache{path: filepath.Join(dir, uploadCacheFile)}
+	if data, err := os.ReadFile(cache.path); err == nil {
+		if err := json.Unmarshal(data, cache); err != nil {
+			debugLog("loadUploadCache: ignoring %s: %v", cache.path, err)
+		}
+	}
+	if cache.Uploads == nil {
+		cache.Uploads = make(map[string]string)
+	}
+	return cache
+}
+
+func (u *uploadCache) save() error {
+	data, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(u.path, append(data, '\n'), 0644)
+}
+
+// uploadAssets uploads the local files of media blocks and points the
+// blocks at the uploads. Paths are relative to dir, the directory of the
+// markdown file.
+func (c *Client) uploadAssets(blocks []Block, dir string) error {
+	if findLocalAsset(blocks) == "" {
+		return nil
+	}
+	cache := loadUploadCache(dir)
+	err := c.uploadBlockAssets(blocks, dir, cache)
+	// Keep what was uploaded even if a later file failed
+	if saveErr := cache.save(); saveErr != nil {
+		debugLog("uploadAssets: failed to save %s: %v", cache.path, saveErr)
+	}
+	return err
+}
+
+func (c *Client) uploadBlockAssets(blocks []Block, dir string, cache *uploadCache) error {
+	for i := range blocks {
+		b := &blocks[i]
+		if b.AssetPath != "" {
+			path := localAssetPath(b.AssetPath, dir)
+			id, err := c.uploadFile(path, cache)
+			if err != nil {
+				return fmt.Errorf("failed to upload %s: %w", b.AssetPath, err)
+			}
+			b.File = &File{Type: "file_upload", FileUpload: &ObjectRef{ID: id}}
+			if b.Type == "file" {
+				b.File.Name = filepath.Base(path)
+			}
+		}
+		if err := c.uploadBlockAssets(b.Children, dir, cache); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localAssetPath resolves a link destination to a file path. Relative
+// paths are relative to dir, and percent-encoded names such as
+// "my%20file.png" are decoded if the file doesn't exist as written.
+func localAssetPath(dest, dir string) string {
+	resolve := func(p string) string {
+		p = filepath.FromSlash(p)
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	path := resolve(dest)
+	if _, err := os.Stat(path); err != nil {
+		if decoded, err := url.PathUnescape(dest); err == nil {
+			return resolve(decoded)
+		}
+	}
+	return path
+}
+
+// uploadFile uploads a file with the file upload API and returns the
+// upload's ID, or the ID of an earlier upload of the same content.
+func (c *Client) uploadFile(path string, cache *uploadCache) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if id, ok := cache.Uploads[hash]; ok {
+		if c.fileUploadUsable(id) {
+			debugLog("uploadFile: %s unchanged, reusing upload %s", path, id)
+			return id, nil
+		}
+		delete(cache.Uploads, hash)
+	}
+
+	if len(data) > maxSinglePartUpload {
+		return "", fmt.Errorf("file is %d bytes, over the %d byte upload limit", len(data), maxSinglePartUpload)
+	}
+
+	name := filepath.Base(path)
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// Create the upload, then send the content to it
+	resp, err := c.doRequest("POST", c.apiBase+"/file_uploads", map[string]any{
+		"filename":     name,
+		"content_type": contentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload: %w", err)
+	}
+	var upload struct {
+		ID        string `json:"id"`
+		UploadURL string `json:"upload_url"`
+	}
+	if err := json.Unmarshal(resp, &upload); err != nil {
+		return "", fmt.Errorf("failed to parse upload: %w", err)
+	}
+	sendURL := upload.UploadURL
+	if sendURL == "" {
+		sendURL = fmt.Sprintf("%s/file_uploads/%s/send", c.apiBase, upload.ID)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": "file", "filename": name}))
+	header.Set("Content-Type", contentType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	debugLog("uploadFile: sending %s (%d bytes) to upload %s", path, len(data), upload.ID)
+	if _, err := c.doRawRequest("POST", sendURL, form.FormDataContentType(), body.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to send file: %w", err)
+	}
+
+	cache.Uploads[hash] = upload.ID
+	return upload.ID, nil
+}
+
+// fileUploadUsable reports whether an earlier upload can be attached to a
+// new block. Uploads that were never attached expire after an hour.
+func (c *Client) fileUploadUsable(id string) bool {
+	resp, err := c.doRequest("GET", fmt.Sprintf("%s/file_uploads/%s", c.apiBase, id), nil)
+	if err != nil {
+		return false
+	}
+	var upload struct {
+		Status string `json:"status"`
+	}
+	return json.Unmarshal(resp, &upload) == nil && upload.Status == "uploaded"
+}
@@ -0,0 +1,186 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeUploadServer implements the parts of the Notion API that uploading
+// and attaching a file use.
+type fakeUploadServer struct {
+	mu       sync.Mutex
+	requests []string
+	uploads  map[string]string // upload ID -> status
+	sent     map[string][]byte // upload ID -> content
+	appended []map[string]any  // blocks appended to pages
+}
+
+func (f *fakeUploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/file_uploads":
+		id := fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[id] = "pending"
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "status": "pending"})
+
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/send"):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/file_uploads/"), "/send")
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, `{"message":"no file"}`, http.StatusBadRequest)
+			return
+		}
+		f.sent[id], _ = io.ReadAll(file)
+		f.uploads[id] = "uploaded"
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "status": "uploaded"})
+
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/file_uploads/"):
+		id := strings.TrimPrefix(r.URL.Path, "/file_uploads/")
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "status": f.uploads[id]})
+
+	case r.Method == "PATCH" && strings.HasSuffix(r.URL.Path, "/children"):
+		var body struct {
+			Children []map[string]any `json:"children"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.appended = append(f.appended, body.Children...)
+		results := make([]map[string]string, len(body.Children))
+		for i := range results {
+			results[i] = map[string]string{"id": fmt.Sprintf("block-%d", len(f.appended)-len(body.Children)+i)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"results": results})
+
+	default:
+		http.Error(w, `{"message":"unexpected request"}`, http.StatusBadRequest)
+	}
+}
+
+func (f *fakeUploadServer) count(request string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+func newUploadTestClient(t *testing.T) (*Client, *fakeUploadServer) {
+	fake := &fakeUploadServer{uploads: map[string]string{}, sent: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	t.Setenv("NOTION_API_KEY", "test-key")
+	t.Setenv("NOTION_API_BASE", srv.URL)
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, fake
+}
+
+func TestUploadAssetsCreatesSendsAndAttaches(t *testing.T) {
+	c, fake := newUploadTestClient(t)
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("fake png content")
+	if err := os.WriteFile(filepath.Join(dir, "assets", "diagram.png"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := MarkdownToBlocks("![Diagram](assets/diagram.png)\n")
+	if err := c.uploadAssets(blocks, dir); err != nil {
+		t.Fatalf("uploadAssets: %v", err)
+	}
+	if err := c.appendBlocksBatched("page-1", blocks); err != nil {
+		t.Fatalf("appendBlocksBatched: %v", err)
+	}
+
+	if got := fake.count("POST /file_uploads"); got != 1 {
+		t.Errorf("created %d uploads, want 1", got)
+	}
+	if got := string(fake.sent["upload-1"]); got != string(content) {
+		t.Errorf("sent %q, want %q", got, content)
+	}
+	if len(fake.appended) != 1 {
+		t.Fatalf("appended %d blocks, want 1", len(fake.appended))
+	}
+	image, _ := fake.appended[0]["image"].(map[string]any)
+	if image["type"] != "file_upload" {
+		t.Errorf("image type = %v, want file_upload", image["type"])
+	}
+	upload, _ := image["file_upload"].(map[string]any)
+	if upload["id"] != "upload-1" {
+		t.Errorf("attached upload %v, want upload-1", upload["id"])
+	}
+
+	cache := loadUploadCache(dir)
+	if len(cache.Uploads) != 1 {
+		t.Errorf("cache has %d uploads, want 1", len(cache.Uploads))
+	}
+}
+
+func TestUploadAssetsReusesCachedUpload(t *testing.T) {
+	c, fake := newUploadTestClient(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.pdf"), []byte("fake pdf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		blocks := MarkdownToBlocks("[Report](report.pdf)\n")
+		if err := c.uploadAssets(blocks, dir); err != nil {
+			t.Fatalf("push %d: uploadAssets: %v", i+1, err)
+		}
+		if blocks[0].File == nil || blocks[0].File.FileUpload == nil || blocks[0].File.FileUpload.ID != "upload-1" {
+			t.Fatalf("push %d: block file = %+v, want upload-1", i+1, blocks[0].File)
+		}
+	}
+
+	if got := fake.count("POST /file_uploads"); got != 1 {
+		t.Errorf("created %d uploads, want 1", got)
+	}
+	if got := fake.count("GET /file_uploads/upload-1"); got != 1 {
+		t.Errorf("checked the cached upload %d times, want 1", got)
+	}
+}
+
+func TestUploadAssetsReplacesExpiredUpload(t *testing.T) {
+	c, fake := newUploadTestClient(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "photo.jpg"), []byte("fake jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.uploadAssets(MarkdownToBlocks("![](photo.jpg)\n"), dir); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	fake.uploads["upload-1"] = "expired"
+	fake.mu.Unlock()
+
+	blocks := MarkdownToBlocks("![](photo.jpg)\n")
+	if err := c.uploadAssets(blocks, dir); err != nil {
+		t.Fatal(err)
+	}
+	if id := blocks[0].File.FileUpload.ID; id != "upload-2" {
+		t.Errorf("attached %s, want a new upload-2", id)
+	}
+	if cached := loadUploadCache(dir).Uploads; len(cached) != 1 {
+		t.Errorf("cache has %d uploads, want 1", len(cached))
+	}
+}